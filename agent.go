@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// agentTokenEnv holds the shared secret for `pulse agent` and for hosts of
+// type agent that don't set their own token.
+const agentTokenEnv = "PULSE_AGENT_TOKEN"
+
+// defaultAgentPort is where `pulse agent` listens unless told otherwise.
+const defaultAgentPort = 9101
+
+// agentPayload is what `pulse agent` serves: one check of the machine it
+// runs on, field for field the parts of HostStatus a local check fills.
+type agentPayload struct {
+	Host            string            `json:"host"`
+	Online          bool              `json:"online"`
+	Metrics         *Metrics          `json:"metrics,omitempty"`
+	Error           string            `json:"error,omitempty"`
+	Failure         FailureReason     `json:"failure,omitempty"`
+	Checks          []CheckResult     `json:"checks,omitempty"`
+	Services        []ServiceStatus   `json:"services,omitempty"`
+	FailedUnits     int               `json:"failed_units,omitempty"`
+	FailedUnitNames []string          `json:"failed_unit_names,omitempty"`
+	Containers      []ContainerStatus `json:"containers,omitempty"`
+	TopCPU          []Process         `json:"top_cpu,omitempty"`
+	TopRSS          []Process         `json:"top_rss,omitempty"`
+	Updates         *Updates          `json:"updates,omitempty"`
+	CheckedAt       time.Time         `json:"checked_at"`
+	ProbeSeconds    float64           `json:"probe_seconds"` // time the agent spent gathering
+}
+
+func newAgentPayload(s HostStatus, took time.Duration) agentPayload {
+	return agentPayload{
+		Host:            s.Config.Host,
+		Online:          s.Online,
+		Metrics:         s.Metrics,
+		Error:           s.Error,
+		Failure:         s.Failure,
+		Checks:          s.Checks,
+		Services:        s.Services,
+		FailedUnits:     s.FailedUnits,
+		FailedUnitNames: s.FailedUnitNames,
+		Containers:      s.Containers,
+		TopCPU:          s.TopCPU,
+		TopRSS:          s.TopRSS,
+		Updates:         s.Updates,
+		CheckedAt:       s.LastCheck,
+		ProbeSeconds:    took.Seconds(),
+	}
+}
+
+// apply copies p into s, sampled into disks at s.LastCheck.
+func (p agentPayload) apply(disks *diskTrends, hc HostConfig, s *HostStatus) {
+	p.sample(disks, hc, s.LastCheck)
+	p.copyTo(s)
+}
+
+// sample filters disks and interfaces again with the polling side's
+// settings and feeds the disk forecast kept there, as for ssh hosts.
+func (p agentPayload) sample(disks *diskTrends, hc HostConfig, at time.Time) {
+	if m := p.Metrics; m != nil {
+		m.Disks = hc.Disks.filter(m.Disks)
+		disks.forecast(hc.Name, at, m.Disks)
+		m.Net = filterInterfaces(m.Net, hc.Interfaces)
+	}
+}
+
+func (p agentPayload) copyTo(s *HostStatus) {
+	s.Online = p.Online
+	s.Error = p.Error
+	s.Failure = p.Failure
+	s.Checks = p.Checks
+	s.Services = p.Services
+	s.FailedUnits = p.FailedUnits
+	s.FailedUnitNames = p.FailedUnitNames
+	s.Containers = p.Containers
+	s.TopCPU = p.TopCPU
+	s.TopRSS = p.TopRSS
+	s.Updates = p.Updates
+	s.Metrics = p.Metrics
+}
+
+// agentChecker polls a `pulse agent` running on the host.
+type agentChecker struct{}
+
+func (agentChecker) Check(ctx context.Context, mon *monitor, hc HostConfig, status *HostStatus) {
+	// The agent probes before it answers, so allow it as long as a probe.
+	ctx, cancel := context.WithTimeout(ctx, max(probeTimeout, hc.timeout()))
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, hc.URL, nil)
+	if err != nil {
+		status.fail(fmt.Errorf("agent: %w", err))
+		return
+	}
+	req.Header.Set("Authorization", "Bearer "+hc.Token)
+	client := &http.Client{Transport: &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: hc.timeout()}).DialContext,
+		TLSHandshakeTimeout: hc.timeout(),
+		DisableKeepAlives:   true,
+	}}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		status.fail(fmt.Errorf("agent: %w", err))
+		return
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		status.Error = "agent: token rejected"
+		status.Failure = FailureAuth
+		return
+	case resp.StatusCode != http.StatusOK:
+		status.Error = fmt.Sprintf("agent: unexpected status %s", resp.Status)
+		status.Failure = FailureCommand
+		return
+	}
+	var p agentPayload
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(&p); err != nil {
+		status.Error = fmt.Sprintf("agent: bad response: %v", err)
+		status.Failure = FailureCommand
+		return
+	}
+	took := time.Duration(p.ProbeSeconds * float64(time.Second))
+	status.Latency = max(time.Since(start)-took, 0)
+	p.apply(mon.disks, hc, status)
+}
+
+// Agent serves the status of the machine it runs on to a polling pulse.
+type Agent struct {
+	mu    sync.Mutex // one probe at a time
+	mon   *monitor   // update checks and disk history between probes
+	hc    HostConfig
+	token string
+	addr  string
+}
+
+func NewAgent(hc HostConfig, token, addr string) *Agent {
+	return &Agent{mon: newMonitor(), hc: hc, token: token, addr: addr}
+}
+
+// Run serves until ctx is cancelled, over TLS when certFile is set.
+func (a *Agent) Run(ctx context.Context, certFile, keyFile string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", a.handleStatus)
+
+	srv := &http.Server{Addr: a.addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	fmt.Printf("Pulse agent for %s on %s\n", a.hc.Label, a.addr)
+	var err error
+	if certFile != "" {
+		err = srv.ListenAndServeTLS(certFile, keyFile)
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+func (a *Agent) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if !validToken(r, a.token) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="pulse"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	status := HostStatus{Config: a.hc, LastCheck: time.Now()}
+	localChecker{}.Check(r.Context(), a.mon, a.hc, &status)
+	payload := newAgentPayload(status, time.Since(status.LastCheck))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payload)
+}
+
+// validToken reports whether r carries "Authorization: Bearer <token>".
+func validToken(r *http.Request, token string) bool {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && token != "" && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// agentHost returns what the agent reports on: the first local host in the
+// pulse config at path (its services, checks, processes and filters), or a
+// plain local host when path is empty.
+func agentHost(path string) (HostConfig, error) {
+	var cfg *Config
+	var err error
+	if path == "" {
+		cfg, err = parseConfig([]byte("hosts: [{type: local}]"))
+	} else {
+		cfg, err = loadConfig(path)
+	}
+	if err != nil {
+		return HostConfig{}, err
+	}
+	for _, h := range cfg.Hosts {
+		if h.Type == "local" {
+			return h, nil
+		}
+	}
+	return HostConfig{}, fmt.Errorf("%s: no host with type: local", path)
+}
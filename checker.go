@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"strings"
@@ -11,70 +14,216 @@ import (
 	"golang.org/x/crypto/ssh/agent"
 )
 
+// checkTimeout is the default for HostConfig.Timeout, bounding dialing and
+// handshakes for every check type.
+const checkTimeout = 5 * time.Second
+
+// probeTimeout bounds the probe script, which may run slow custom checks.
+const probeTimeout = 30 * time.Second
+
 type HostStatus struct {
 	Config    HostConfig
 	Online    bool
-	CPU       string // load average
-	Memory    string // used/total
-	Disk      string // used%
-	Uptime    string
+	Metrics   *Metrics      // nil when the check type collects none
+	Latency   time.Duration // time to connect / get a response
+	Detail    string        // type-specific summary, e.g. "HTTP 200"
+	Health    Health
+	Reasons   []string // why Health is not ok
 	LastCheck time.Time
 	Error     string
+	Failure   FailureReason // classification of Error, empty when the check succeeded
+	Attempts  int           // tries this check took, including retries
+	Pending   *PendingState // set by StateTracker while a state change awaits confirmation
+	Interval  time.Duration // effective time until the next scheduled check
+
+	HostKeyMismatch bool   // server key differs from the one on file
+	FailedHop       string // jump host that broke, if the chain failed before the target
+
+	Checks          []CheckResult   // user-defined custom checks
+	Services        []ServiceStatus // watched systemd units
+	FailedUnits     int             // failed systemd units on the host
+	FailedUnitNames []string        // first few of them
+	Containers      []ContainerStatus
+
+	TopCPU []Process // busiest processes, when HostConfig.Processes > 0
+	TopRSS []Process // largest by resident memory
+
+	Updates *Updates // pending packages and reboot state, refreshed every UpdatesInterval
 }
 
-func checkHost(hc HostConfig) HostStatus {
+// Checker probes a single host and fills in its HostStatus, keeping what
+// outlives the check in mon. Checks stop early when ctx is cancelled.
+type Checker interface {
+	Check(ctx context.Context, mon *monitor, hc HostConfig, status *HostStatus)
+}
+
+// checkers maps HostConfig.Type to its implementation.
+var checkers = map[string]Checker{
+	"ssh":       sshChecker{},
+	"tcp":       tcpChecker{},
+	"http":      httpChecker{},
+	"dns":       dnsChecker{},
+	"tls":       tlsChecker{},
+	"local":     localChecker{},
+	"agent":     agentChecker{},
+	"heartbeat": heartbeatChecker{},
+}
+
+func (mon *monitor) checkHost(ctx context.Context, hc HostConfig) HostStatus {
 	status := HostStatus{
 		Config:    hc,
 		LastCheck: time.Now(),
 	}
+	c, ok := checkers[hc.Type]
+	if !ok {
+		c = sshChecker{}
+	}
+	backoff := time.Duration(hc.RetryBackoff * float64(time.Second))
+	for {
+		status.Attempts++
+		c.Check(ctx, mon, hc, &status)
+		if status.Online || status.Attempts > *hc.Retries || !status.Failure.retryable() {
+			break
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		backoff *= 2
+		status = HostStatus{Config: hc, LastCheck: time.Now(), Attempts: status.Attempts}
+	}
+	if ctx.Err() != nil {
+		return status // cancelled: don't let a partial result feed rate history
+	}
+	// Heartbeat hosts get their rates between pushes, not between checks.
+	if prev, ok := mon.results.swap(status); ok && hc.Type != "heartbeat" && status.Metrics != nil && prev.Metrics != nil {
+		netRates(status.Metrics.Net, prev.Metrics.Net, status.LastCheck.Sub(prev.LastCheck))
+	}
+	evaluateHealth(&status)
+	return status
+}
+
+// sshChecker logs in over SSH and gathers everything with one probe script.
+type sshChecker struct{}
 
-	client, err := sshConnect(hc)
+func (sshChecker) Check(ctx context.Context, mon *monitor, hc HostConfig, status *HostStatus) {
+	start := time.Now()
+	client, err := mon.pool.Get(ctx, hc)
 	if err != nil {
-		status.Online = false
-		status.Error = err.Error()
-		return status
+		var keyErr *HostKeyError
+		status.HostKeyMismatch = errors.As(err, &keyErr) && !keyErr.Unknown
+		var hopErr *HopError
+		if errors.As(err, &hopErr) && !hopErr.Target {
+			status.FailedHop = hopErr.Hop
+		}
+		status.fail(err)
+		return
 	}
-	defer client.Close()
+	status.Latency = time.Since(start)
 
-	status.Online = true
-
-	// Get load average
-	if out, err := runCommand(client, "cat /proc/loadavg 2>/dev/null || sysctl -n vm.loadavg 2>/dev/null"); err == nil {
-		parts := strings.Fields(strings.Trim(out, "{ }"))
-		if len(parts) >= 3 {
-			status.CPU = fmt.Sprintf("%s %s %s", parts[0], parts[1], parts[2])
+	// Gather everything in one session; a non-zero exit from the last
+	// command is fine as long as the output parses. A probe that does not
+	// run at all fails the check like a refused login.
+	pctx, cancel := context.WithTimeout(ctx, max(probeTimeout, hc.timeout()))
+	defer cancel()
+	out, err := runScript(pctx, client, probeScript(hc, mon.updates.due(hc)))
+	probe, perr := parseProbe(out)
+	if perr != nil {
+		if err != nil && ctx.Err() == nil {
+			// The session itself failed; don't reuse this connection.
+			mon.pool.Invalidate(hc)
+			perr = err
 		}
+		status.fail(perr)
+		return
 	}
+	status.Online = true
+	mon.applyProbe(status, hc, probe, probe.metrics())
+}
 
-	// Get memory (Linux: free, macOS: vm_stat + sysctl)
-	if out, err := runCommand(client, `free -h 2>/dev/null | awk '/^Mem:/{print $3"/"$2}'`); err == nil && strings.TrimSpace(out) != "" {
-		status.Memory = strings.TrimSpace(out)
-	} else if out, err := runCommand(client, `echo $(( $(vm_stat 2>/dev/null | awk '/Pages active|Pages wired/{gsub(/\./,"",$NF);s+=$NF}END{print s}') * 4096 / 1048576 ))Mi/$(( $(sysctl -n hw.memsize 2>/dev/null) / 1048576 ))Mi`); err == nil && strings.TrimSpace(out) != "" && !strings.Contains(out, "error") {
-		status.Memory = strings.TrimSpace(out)
+// applyProbe fills s from probe output and the metrics taken from it (or
+// gathered some other way, see localChecker).
+func (mon *monitor) applyProbe(s *HostStatus, hc HostConfig, probe probeResult, m *Metrics) {
+	if !probe.complete() {
+		// The script died part way through.
+		s.Error = "probe: output ended early"
+		s.Failure = FailurePartial
+	}
+	s.Metrics = m
+	s.Metrics.Disks = hc.Disks.filter(s.Metrics.Disks)
+	mon.disks.forecast(hc.Name, s.LastCheck, s.Metrics.Disks)
+	s.Metrics.Net = filterInterfaces(s.Metrics.Net, hc.Interfaces)
+	s.Checks = probe.customResults(hc.Checks)
+	s.Services = probe.services()
+	s.Containers = probe.containers(hc.Containers)
+	s.TopCPU = parseProcesses(probe["top_cpu"])
+	s.TopRSS = parseProcesses(probe["top_rss"])
+	if n, ok := probe.uint("failed_units"); ok {
+		s.FailedUnits = int(n)
+		s.FailedUnitNames = probe["failed_unit"]
 	}
+	s.Updates = mon.updates.result(hc, probe, s.LastCheck)
+}
 
-	// Get disk usage
-	if out, err := runCommand(client, `df -h / 2>/dev/null | awk 'NR==2{print $5}'`); err == nil {
-		status.Disk = strings.TrimSpace(out)
+// HopError reports which hop of a jump chain failed.
+type HopError struct {
+	Hop    string // jump host label, or the target's host
+	Target bool   // the final target failed rather than a jump host
+	Err    error
+}
+
+func (e *HopError) Error() string {
+	role := "jump host"
+	if e.Target {
+		role = "target"
+	}
+	var de *dialError
+	switch {
+	case !errors.As(e.Err, &de):
+		return fmt.Sprintf("%s %s: %v", role, e.Hop, e.Err)
+	case strings.Contains(de.Error(), "refused"):
+		return fmt.Sprintf("%s %s refused connection: %v", role, e.Hop, de.err)
+	default:
+		return fmt.Sprintf("%s %s unreachable: %v", role, e.Hop, de.err)
 	}
+}
+
+func (e *HopError) Unwrap() error { return e.Err }
+
+// dialError marks a failure to open the TCP connection itself.
+type dialError struct{ err error }
 
-	// Get uptime
-	if out, err := runCommand(client, `uptime -p 2>/dev/null || uptime | sed 's/.*up //' | sed 's/,.*//'`); err == nil {
-		u := strings.TrimSpace(out)
-		// Clean up macOS uptime output
-		if strings.Contains(u, "load") {
-			parts := strings.SplitN(u, ",", 3)
-			if len(parts) >= 1 {
-				u = strings.TrimSpace(parts[0])
+func (e *dialError) Error() string { return "dial: " + e.err.Error() }
+func (e *dialError) Unwrap() error { return e.err }
+
+// sshConnect opens an authenticated client to hc, tunnelling through any
+// jump hosts first. With jumps, failures are reported as a *HopError.
+func sshConnect(ctx context.Context, hc HostConfig) (*ssh.Client, error) {
+	var via *ssh.Client
+	for _, hop := range hc.jumps {
+		c, err := sshDial(ctx, hop, via)
+		if err != nil {
+			if via != nil {
+				via.Close()
 			}
+			return nil, &HopError{Hop: hop.Label, Err: err}
 		}
-		status.Uptime = u
+		via = c
 	}
-
-	return status
+	client, err := sshDial(ctx, hc, via)
+	if err != nil && via != nil {
+		via.Close()
+		return nil, &HopError{Hop: hc.Host, Target: true, Err: err}
+	}
+	return client, err
 }
 
-func sshConnect(hc HostConfig) (*ssh.Client, error) {
+// sshDial connects to hc directly, or through via when it is non-nil. The
+// returned client closes via when it is closed.
+func sshDial(ctx context.Context, hc HostConfig, via *ssh.Client) (*ssh.Client, error) {
 	var authMethods []ssh.AuthMethod
 
 	// Try SSH agent first (covers macOS Keychain keys)
@@ -86,9 +235,12 @@ func sshConnect(hc HostConfig) (*ssh.Client, error) {
 		}
 	}
 
-	// Try key file
-	if hc.KeyFile != "" {
-		key, err := os.ReadFile(expandHome(hc.KeyFile))
+	// Try key file, then IdentityFile entries from ~/.ssh/config
+	for _, file := range append([]string{hc.KeyFile}, hc.identityFiles...) {
+		if file == "" {
+			continue
+		}
+		key, err := os.ReadFile(expandHome(file))
 		if err == nil {
 			signer, err := ssh.ParsePrivateKey(key)
 			if err == nil {
@@ -123,34 +275,71 @@ func sshConnect(hc HostConfig) (*ssh.Client, error) {
 	config := &ssh.ClientConfig{
 		User:            hc.User,
 		Auth:            authMethods,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         5 * time.Second,
+		HostKeyCallback: hostKeyCallback(hc),
+		Timeout:         hc.timeout(),
 	}
 
-	addr := fmt.Sprintf("%s:%d", hc.Host, hc.Port)
-	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, hc.timeout())
+	defer cancel()
+	addr := hc.Address()
+	conn, err := dialVia(ctx, via, addr)
 	if err != nil {
-		return nil, fmt.Errorf("dial: %w", err)
+		return nil, &dialError{err}
 	}
+	config.HostKeyAlgorithms = hostKeyAlgorithms(hc, addr, conn.RemoteAddr())
 
+	// NewClientConn has no deadline of its own; closing the connection
+	// aborts a handshake that outlives ctx.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
 	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if !stop() {
+		if err == nil {
+			c.Close()
+		}
+		return nil, fmt.Errorf("ssh handshake: %w", ctx.Err())
+	}
 	if err != nil {
 		conn.Close()
-		return nil, fmt.Errorf("ssh: %w", err)
+		return nil, err
+	}
+
+	client := ssh.NewClient(c, chans, reqs)
+	if via != nil {
+		go func() {
+			client.Wait() //nolint:errcheck
+			via.Close()
+		}()
 	}
+	return client, nil
+}
 
-	return ssh.NewClient(c, chans, reqs), nil
+// dialVia opens a TCP connection to addr, through an SSH client if given.
+func dialVia(ctx context.Context, via *ssh.Client, addr string) (net.Conn, error) {
+	if via == nil {
+		var d net.Dialer
+		return d.DialContext(ctx, "tcp", addr)
+	}
+	return via.DialContext(ctx, "tcp", addr)
 }
 
-func runCommand(client *ssh.Client, cmd string) (string, error) {
+// runCommand runs cmd in a new session, writing stdout and stderr to out
+// as they arrive. out must be safe for concurrent writes.
+func runCommand(ctx context.Context, client *ssh.Client, cmd string, out io.Writer) error {
 	session, err := client.NewSession()
 	if err != nil {
-		return "", err
+		return err
 	}
 	defer session.Close()
+	stop := context.AfterFunc(ctx, func() { session.Close() })
+	defer stop()
 
-	out, err := session.CombinedOutput(cmd)
-	return string(out), err
+	session.Stdout = out
+	session.Stderr = out
+	err = session.Run(cmd)
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	return err
 }
 
 func expandHome(path string) string {
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestProbeFailureFailsCheck(t *testing.T) {
+	t.Run("ssh session refused", func(t *testing.T) {
+		ed, _, _ := testSigners(t)
+		addr := testSSHServer(t, ed) // logs in, but refuses every session
+		hc := testHostConfig(t, addr, ed.PublicKey())
+		hc.Name = "no-session"
+		mon := newMonitor()
+		t.Cleanup(mon.close)
+		s := mon.checkHost(t.Context(), hc)
+		if s.Online || s.Failure != FailureCommand || s.Health != HealthCritical || s.State() != "down" {
+			t.Errorf("status = online %v, failure %s, health %s, state %s; want down with command_failed",
+				s.Online, s.Failure, s.Health, s.State())
+		}
+		if s.Metrics != nil {
+			t.Errorf("metrics = %+v, want none", s.Metrics)
+		}
+	})
+	t.Run("local sh missing", func(t *testing.T) {
+		t.Setenv("PATH", t.TempDir())
+		s := newMonitor().checkHost(t.Context(), HostConfig{Name: "no-sh", Type: "local", Retries: new(int), Timeout: 5})
+		if s.Online || s.Failure != FailureCommand || s.State() != "down" {
+			t.Errorf("status = online %v, failure %s, state %s; want down with command_failed", s.Online, s.Failure, s.State())
+		}
+	})
+}
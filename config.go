@@ -2,35 +2,156 @@ package main
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 type HostConfig struct {
-	Name     string `yaml:"name"`
+	Name     string `yaml:"name"` // unique; default: Host
+	Type     string `yaml:"type"` // ssh (default), local, agent, heartbeat, tcp, http, dns, tls
 	Host     string `yaml:"host"`
 	User     string `yaml:"user"`
 	Port     int    `yaml:"port"`
 	KeyFile  string `yaml:"key_file"`
 	Password string `yaml:"password"`
 	Label    string `yaml:"label"`
+
+	HostKeyCheck   string `yaml:"host_key_check"`   // tofu, strict or off (default: Config.HostKeyCheck)
+	KnownHostsFile string `yaml:"known_hosts_file"` // pulse-managed known_hosts (default: Config.KnownHostsFile)
+
+	Jump []string `yaml:"jump"` // jump hosts, outermost first: names of other hosts or user@host:port
+
+	Interval    int `yaml:"interval"`     // seconds between checks (default: Config.Interval)
+	MinInterval int `yaml:"min_interval"` // seconds between checks while in trouble (default: Config.MinInterval)
+	MaxInterval int `yaml:"max_interval"` // cap for the doubling trouble interval (default: Interval)
+
+	Timeout      float64 `yaml:"timeout"`       // seconds per attempt (default: Config.Timeout)
+	Retries      *int    `yaml:"retries"`       // extra attempts after a failure; 0 turns them off (default: Config.Retries)
+	RetryBackoff float64 `yaml:"retry_backoff"` // seconds before the first retry, doubling after (default: Config.RetryBackoff)
+	ConfirmDown  int     `yaml:"confirm_down"`  // consecutive failures before DOWN is announced (default: Config.ConfirmDown)
+	ConfirmUp    int     `yaml:"confirm_up"`    // consecutive successes before UP is announced (default: Config.ConfirmUp)
+
+	URL          string  `yaml:"url"`           // http: URL to fetch (default http://host:port/)
+	ExpectStatus int     `yaml:"expect_status"` // http: required status code (default any 2xx/3xx)
+	Query        string  `yaml:"query"`         // dns: name to resolve against host (default: resolve host itself)
+	Token        string  `yaml:"token"`         // agent, heartbeat: shared secret (default: $PULSE_AGENT_TOKEN)
+	Grace        float64 `yaml:"grace"`         // heartbeat: seconds without a push before DOWN (default: 2 × Interval)
+
+	Thresholds Thresholds      `yaml:"thresholds"` // overrides Config.Thresholds per field
+	Checks     []CustomCheck   `yaml:"checks"`     // added to Config.Checks; same name replaces
+	Services   []string        `yaml:"services"`   // systemd units to watch, e.g. nginx
+	Containers ContainerConfig `yaml:"containers"` // docker/podman inventory
+	Disks      DiskConfig      `yaml:"disks"`      // overrides Config.Disks per field
+	Interfaces []string        `yaml:"interfaces"` // interface globs (default: all but lo*, veth*)
+	Processes  int             `yaml:"processes"`  // top N processes by CPU and memory to capture (default 0: off)
+
+	UpdatesInterval int `yaml:"updates_interval"` // seconds between package update checks, -1 to disable (default: Config.UpdatesInterval)
+
+	identityFiles []string     // IdentityFile entries from ~/.ssh/config
+	jumps         []HostConfig // hops to tunnel through, outermost first
+}
+
+// Address returns host:port suitable for net.Dial.
+func (hc HostConfig) Address() string {
+	return net.JoinHostPort(hc.Host, strconv.Itoa(hc.Port))
+}
+
+// interval returns the time between checks of this host.
+func (hc HostConfig) interval() time.Duration {
+	return time.Duration(hc.Interval) * time.Second
+}
+
+// timeout returns the per-attempt timeout for dialing and handshakes.
+func (hc HostConfig) timeout() time.Duration {
+	if hc.Timeout <= 0 {
+		return checkTimeout
+	}
+	return time.Duration(hc.Timeout * float64(time.Second))
+}
+
+// grace returns how long a heartbeat host may go without a push.
+func (hc HostConfig) grace() time.Duration {
+	if hc.Grace <= 0 {
+		return 2 * hc.interval()
+	}
+	return time.Duration(hc.Grace * float64(time.Second))
+}
+
+// Target returns a short human-readable description of what is checked.
+func (hc HostConfig) Target() string {
+	switch hc.Type {
+	case "ssh":
+		return fmt.Sprintf("%s@%s", hc.User, hc.Host)
+	case "http", "agent":
+		return hc.URL
+	case "local":
+		return "local " + hc.Host
+	case "heartbeat":
+		return "heartbeat " + hc.Name
+	default:
+		return fmt.Sprintf("%s %s", hc.Type, hc.Address())
+	}
+}
+
+// defaultPort returns the conventional port for a check type.
+func defaultPort(checkType string) int {
+	switch checkType {
+	case "http":
+		return 80
+	case "dns":
+		return 53
+	case "tls":
+		return 443
+	case "agent":
+		return defaultAgentPort
+	default:
+		return 22
+	}
 }
 
 type NotifyConfig struct {
 	Webhook string `yaml:"webhook"` // POST URL for state changes
-	Command string `yaml:"command"` // shell command, {host} {label} {state} replaced
+	Command string `yaml:"command"` // shell command; {host} {label} {component} {state} {reason} {failure} expand to their $PULSE_* variables
+
+	// Limit a notifier's down/up events to these failure reasons (default: all).
+	WebhookFailures []FailureReason `yaml:"webhook_failures"`
+	CommandFailures []FailureReason `yaml:"command_failures"`
 }
 
 type Config struct {
-	Interval     int          `yaml:"interval"` // seconds
-	Hosts        []HostConfig `yaml:"hosts"`
-	Notify       NotifyConfig `yaml:"notify"`
-	JiraURL      string       `yaml:"jira_url"`
-	JiraEmail    string       `yaml:"jira_email"`
-	JiraToken    string       `yaml:"jira_token"`
-	DispatchFile string       `yaml:"dispatch_file"`
+	Interval   int           `yaml:"interval"` // seconds, default for HostConfig.Interval
+	Hosts      []HostConfig  `yaml:"hosts"`
+	Notify     NotifyConfig  `yaml:"notify"`
+	Thresholds Thresholds    `yaml:"thresholds"`
+	Checks     []CustomCheck `yaml:"checks"` // custom checks run on every ssh host
+	Disks      DiskConfig    `yaml:"disks"`  // mount filters and fill forecast alerting
+
+	MinInterval  int     `yaml:"min_interval"`  // seconds between checks of a host in trouble, default 5
+	MaxInterval  int     `yaml:"max_interval"`  // cap while trouble lasts, default each host's interval
+	Concurrency  int     `yaml:"concurrency"`   // hosts checked at once, default 16
+	Timeout      float64 `yaml:"timeout"`       // seconds per attempt, default 5
+	Retries      int     `yaml:"retries"`       // extra attempts after a failure, default 0
+	RetryBackoff float64 `yaml:"retry_backoff"` // seconds before the first retry, default 1
+	ConfirmDown  int     `yaml:"confirm_down"`  // failures in a row before DOWN, default 1
+	ConfirmUp    int     `yaml:"confirm_up"`    // successes in a row before UP, default 1
+
+	UpdatesInterval int `yaml:"updates_interval"` // seconds between package update checks, default 3600; -1 disables
+
+	HostKeyCheck   string `yaml:"host_key_check"`   // tofu (default), strict or off
+	KnownHostsFile string `yaml:"known_hosts_file"` // default ~/.config/pulse/known_hosts
+	SSHConfigFile  string `yaml:"ssh_config"`       // default ~/.ssh/config
+
+	JiraURL      string `yaml:"jira_url"`
+	JiraEmail    string `yaml:"jira_email"`
+	JiraToken    string `yaml:"jira_token"`
+	DispatchFile string `yaml:"dispatch_file"`
 }
 
 func defaultConfigPath() string {
@@ -43,7 +164,11 @@ func loadConfig(path string) (*Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("read config: %w", err)
 	}
+	return parseConfig(data)
+}
 
+// parseConfig parses a config file's contents and fills in defaults.
+func parseConfig(data []byte) (*Config, error) {
 	var cfg Config
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
 		return nil, fmt.Errorf("parse config: %w", err)
@@ -52,19 +177,229 @@ func loadConfig(path string) (*Config, error) {
 	if cfg.Interval <= 0 {
 		cfg.Interval = 30
 	}
+	if cfg.MinInterval <= 0 {
+		cfg.MinInterval = 5
+	}
+	if cfg.UpdatesInterval == 0 {
+		cfg.UpdatesInterval = 3600
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = checkTimeout.Seconds()
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = 1
+	}
+	cfg.ConfirmDown = max(cfg.ConfirmDown, 1)
+	cfg.ConfirmUp = max(cfg.ConfirmUp, 1)
+	if cfg.HostKeyCheck == "" {
+		cfg.HostKeyCheck = hostKeyTOFU
+	}
+	if cfg.KnownHostsFile == "" {
+		cfg.KnownHostsFile = defaultKnownHostsPath()
+	}
+	if cfg.SSHConfigFile == "" {
+		cfg.SSHConfigFile = defaultSSHConfigPath()
+	}
+	cfg.SSHConfigFile = expandHome(cfg.SSHConfigFile)
+
+	for _, r := range append(cfg.Notify.WebhookFailures, cfg.Notify.CommandFailures...) {
+		if !slices.Contains(failureReasons, r) {
+			return nil, fmt.Errorf("notify: unknown failure reason %q", r)
+		}
+	}
 
+	names := make(map[string]bool)
 	for i := range cfg.Hosts {
-		if cfg.Hosts[i].Port == 0 {
-			cfg.Hosts[i].Port = 22
+		h := &cfg.Hosts[i]
+		h.Type = strings.ToLower(h.Type)
+		if h.Type == "" {
+			h.Type = "ssh"
+		}
+		if _, ok := checkers[h.Type]; !ok {
+			return nil, fmt.Errorf("host %q: unknown check type %q", h.Name, h.Type)
+		}
+		if h.Type == "tcp" && h.Port == 0 {
+			return nil, fmt.Errorf("host %q: tcp check requires a port", h.Name)
+		}
+		if h.Type == "local" && h.Host == "" {
+			h.Host, _ = os.Hostname()
+		}
+		if h.Name == "" {
+			h.Name = h.Host
+		}
+		// State, history and the web pages are all keyed by name.
+		if h.Name != "" && names[h.Name] {
+			return nil, fmt.Errorf("host %q: name is used by another host; give each a unique name", h.Name)
+		}
+		names[h.Name] = true
+		if h.Type == "ssh" {
+			if err := applySSHConfig(h, cfg.SSHConfigFile, 0); err != nil {
+				return nil, fmt.Errorf("host %q: %w", h.Name, err)
+			}
+		}
+		if h.Port == 0 {
+			h.Port = defaultPort(h.Type)
+		}
+		if h.Type == "http" && h.URL == "" {
+			h.URL = "http://" + h.Address() + "/"
 		}
-		if cfg.Hosts[i].Label == "" {
-			cfg.Hosts[i].Label = cfg.Hosts[i].Name
+		if h.Type == "agent" && h.URL == "" {
+			h.URL = "http://" + h.Address() + "/status"
+		}
+		if h.Type == "agent" || h.Type == "heartbeat" {
+			if h.Token == "" {
+				h.Token = os.Getenv(agentTokenEnv)
+			}
+			if h.Token == "" {
+				return nil, fmt.Errorf("host %q: %s needs a token (or %s)", h.Name, h.Type, agentTokenEnv)
+			}
+		}
+		if h.Type == "heartbeat" && h.Name == "" {
+			return nil, fmt.Errorf("heartbeat host needs a name to push to")
+		}
+		if h.Label == "" {
+			h.Label = h.Name
+		}
+		if h.Interval <= 0 {
+			h.Interval = cfg.Interval
+		}
+		if h.MinInterval <= 0 {
+			h.MinInterval = cfg.MinInterval
+		}
+		if h.MaxInterval <= 0 {
+			h.MaxInterval = cfg.MaxInterval
+		}
+		h.MinInterval = min(h.MinInterval, h.Interval)
+		if h.MaxInterval <= 0 || h.MaxInterval > h.Interval {
+			h.MaxInterval = h.Interval
+		}
+		h.MaxInterval = max(h.MaxInterval, h.MinInterval)
+		if h.UpdatesInterval == 0 {
+			h.UpdatesInterval = cfg.UpdatesInterval
+		}
+		if h.Timeout <= 0 {
+			h.Timeout = cfg.Timeout
+		}
+		if h.Retries == nil {
+			retries := cfg.Retries
+			h.Retries = &retries
+		}
+		if h.RetryBackoff <= 0 {
+			h.RetryBackoff = cfg.RetryBackoff
+		}
+		if h.ConfirmDown <= 0 {
+			h.ConfirmDown = cfg.ConfirmDown
+		}
+		if h.ConfirmUp <= 0 {
+			h.ConfirmUp = cfg.ConfirmUp
+		}
+		h.Thresholds = defaultThresholds.merge(cfg.Thresholds).merge(h.Thresholds)
+		h.Disks = defaultDiskConfig.merge(cfg.Disks).merge(h.Disks)
+		if h.HostKeyCheck == "" {
+			h.HostKeyCheck = cfg.HostKeyCheck
+		}
+		switch h.HostKeyCheck {
+		case hostKeyTOFU, hostKeyStrict, hostKeyOff:
+		default:
+			return nil, fmt.Errorf("host %q: unknown host_key_check %q", h.Name, h.HostKeyCheck)
+		}
+		if h.KnownHostsFile == "" {
+			h.KnownHostsFile = cfg.KnownHostsFile
+		}
+		for j := range h.jumps {
+			h.jumps[j].HostKeyCheck = h.HostKeyCheck
+			h.jumps[j].KnownHostsFile = h.KnownHostsFile
+			h.jumps[j].Timeout = h.Timeout
+		}
+		if h.Type == "ssh" || h.Type == "local" {
+			h.Checks = mergeChecks(cfg.Checks, h.Checks)
+		} else if len(h.Checks) > 0 || len(h.Services) > 0 || h.Containers.Enabled || h.Processes > 0 {
+			return nil, fmt.Errorf("host %q: checks, services, containers and processes need an ssh or local host", h.Name)
+		}
+		switch h.Containers.Runtime {
+		case "", "auto", "docker", "podman":
+		default:
+			return nil, fmt.Errorf("host %q: unknown container runtime %q", h.Name, h.Containers.Runtime)
+		}
+		for j := range h.Checks {
+			if err := h.Checks[j].compile(); err != nil {
+				return nil, fmt.Errorf("host %q: %w", h.Name, err)
+			}
+		}
+		if len(h.Jump) > 0 && h.Type != "ssh" {
+			return nil, fmt.Errorf("host %q: jump is only supported for ssh checks", h.Name)
 		}
 	}
 
+	if err := resolveJumps(&cfg); err != nil {
+		return nil, err
+	}
+
 	return &cfg, nil
 }
 
+// resolveJumps expands each host's jump list into the chain of hops to
+// tunnel through. Entries naming another configured ssh host reuse its
+// settings (and its own jumps); anything else is parsed as user@host:port
+// and looked up in ~/.ssh/config. An explicit jump list replaces ProxyJump.
+func resolveJumps(cfg *Config) error {
+	byName := make(map[string]*HostConfig)
+	for i := range cfg.Hosts {
+		if cfg.Hosts[i].Type == "ssh" {
+			byName[cfg.Hosts[i].Name] = &cfg.Hosts[i]
+		}
+	}
+
+	var resolve func(h *HostConfig, seen map[string]bool) ([]HostConfig, error)
+	resolve = func(h *HostConfig, seen map[string]bool) ([]HostConfig, error) {
+		if len(h.Jump) == 0 {
+			return h.jumps, nil
+		}
+		var chain []HostConfig
+		for _, ref := range h.Jump {
+			if ref == "" {
+				continue
+			}
+			var hop HostConfig
+			if target, ok := byName[ref]; ok {
+				if seen[ref] {
+					return nil, fmt.Errorf("host %q: jump loop through %q", h.Name, ref)
+				}
+				seen[ref] = true
+				sub, err := resolve(target, seen)
+				delete(seen, ref)
+				if err != nil {
+					return nil, err
+				}
+				chain = append(chain, sub...)
+				hop = *target
+			} else {
+				hop = parseJumpSpec(ref)
+				if err := applySSHConfig(&hop, cfg.SSHConfigFile, 0); err != nil {
+					return nil, fmt.Errorf("host %q: jump %q: %w", h.Name, ref, err)
+				}
+				chain = append(chain, hop.jumps...)
+				hop.HostKeyCheck = h.HostKeyCheck
+				hop.KnownHostsFile = h.KnownHostsFile
+				hop.Timeout = h.Timeout
+			}
+			hop.Jump, hop.jumps = nil, nil
+			chain = append(chain, hop)
+		}
+		return chain, nil
+	}
+
+	for i := range cfg.Hosts {
+		h := &cfg.Hosts[i]
+		chain, err := resolve(h, map[string]bool{h.Name: true})
+		if err != nil {
+			return err
+		}
+		h.jumps = chain
+	}
+	return nil
+}
+
 func writeDefaultConfig(path string) error {
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -72,16 +407,100 @@ func writeDefaultConfig(path string) error {
 	}
 
 	sample := `# Pulse - Host Monitor Configuration
-interval: 30  # seconds between checks
+interval: 30  # seconds between checks; hosts can set their own
+
+# concurrency: 16  # hosts checked at once
+# Hosts that are down, degraded or changing state are rechecked after
+# min_interval, doubling while the trouble lasts up to max_interval.
+# min_interval: 5
+# max_interval: 30  # default: the host's interval
+
+# Pending package updates and reboot-required are checked less often.
+# updates_interval: 3600  # seconds; -1 disables, hosts can override
+
+# A host is retried before it counts as failed, and only announced DOWN
+# (or back UP) after several results in a row. Hosts can override these.
+# timeout: 5         # seconds per attempt
+# retries: 1
+# retry_backoff: 1   # seconds, doubling per retry
+# confirm_down: 2
+# confirm_up: 1
+
+# SSH host key checking: tofu pins unseen keys to ~/.config/pulse/known_hosts,
+# strict only trusts keys already in a known_hosts file, off disables checks.
+# host_key_check: tofu
+
+# Health thresholds (warn turns a host yellow, crit red). Hosts can override.
+# thresholds:
+#   load:    { warn: 4, crit: 8 }       # 1-minute load average
+#   memory:  { warn: 85, crit: 95 }     # percent used
+#   disk:    { warn: 80, crit: 90 }     # percent used, any mount
+#   latency: { warn: 500, crit: 2000 }  # milliseconds
+#   net_rate:   { warn: 500, crit: 900 }  # Mbit/s in or out, any interface
+#   net_errors: { warn: 1, crit: 10 }     # errors + drops per second
+#   temperature: { warn: 75, crit: 85 }   # °C, hottest sensor
+#   battery:     { warn: 20, crit: 10 }   # percent left while on battery
+
+# Disks: every real filesystem is checked (space and inodes). Hosts can
+# override. Alerts fire when a mount is forecast to fill within the horizon.
+# disks:
+#   exclude: ["/boot*", "/snap/*"]
+#   fill_warn_hours: 72
+#   fill_crit_hours: 24
 
 hosts:
+  # Aliases from ~/.ssh/config work too (HostName, User, Port,
+  # IdentityFile, ProxyJump): just say "host: pi".
   - name: example
     host: 192.168.1.100
     user: admin
     port: 22
     label: "Example Server"
+    # interval: 300  # check this host less often than the global interval
     # key_file: ~/.ssh/id_ed25519
     # password: use key_file instead
+    # jump: [bastion]  # other host names or user@host:port, outermost first
+
+    # services: [nginx, postgresql]  # systemd units to watch
+    # processes: 5   # snapshot the top 5 processes by CPU and memory
+    # containers:    # docker/podman inventory
+    #   enabled: true
+    #   pinned: [web, db]  # alert if not running or unhealthy
+    # checks:        # custom commands; also settable globally
+    #   - name: zpool
+    #     command: zpool status -x
+    #     match: "all pools are healthy"
+    #     timeout: 20  # seconds, default 10
+    #   - name: backup-age-hours
+    #     command: echo $(( ($(date +%s) - $(stat -c %Y /backup/latest.tar)) / 3600 ))
+    #     extract: '(\d+)'
+    #     warn: 24
+    #     crit: 48
+
+  # The machine pulse runs on, read directly (no SSH); services,
+  # containers, processes and checks work as for ssh hosts.
+  # - name: this-box
+  #   type: local
+
+  # Hosts running "pulse agent" (behind NAT, or no SSH keys allowed);
+  # services, checks etc. are set in the agent's own config.
+  # - name: laptop
+  #   type: agent
+  #   host: 10.0.0.42   # port 9101, or set url: https://.../status
+  #   token: secret     # default: $PULSE_AGENT_TOKEN
+
+  # Cron jobs and occasional laptops push to the web dashboard instead
+  # (POST /api/heartbeat/<name>); DOWN after grace seconds without one.
+  # - name: nightly-backup
+  #   type: heartbeat
+  #   token: secret
+  #   grace: 90000
+
+  # Non-SSH checks: type can be tcp, http, dns or tls
+  # - name: router
+  #   type: http
+  #   url: http://192.168.1.1/
+  #   expect_status: 200
 `
 	return os.WriteFile(path, []byte(sample), 0644)
 }
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseConfigHostNames(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	tests := []struct {
+		name    string
+		hosts   string
+		wantErr string
+	}{
+		{"distinct", "  - {host: a}\n  - {host: b}\n", ""},
+		{"same host, named apart", "  - {name: pi-ssh, host: pi}\n  - {name: pi-web, host: pi, type: http}\n", ""},
+		{"same host twice", "  - {host: pi, user: root}\n  - {host: pi, port: 2222}\n", `host "pi": name is used by another host`},
+		{"name clashes with a host", "  - {host: pi}\n  - {name: pi, host: 10.0.0.5, type: tcp, port: 80}\n", `host "pi": name is used`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseConfig([]byte("hosts:\n" + tt.hosts))
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("parseConfig: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("parseConfig error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseConfigRetries(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cfg, err := parseConfig([]byte(`retries: 2
+hosts:
+  - {host: a}
+  - {host: b, retries: 0}
+  - {host: c, retries: 5}
+`))
+	if err != nil {
+		t.Fatalf("parseConfig: %v", err)
+	}
+	for i, want := range []int{2, 0, 5} {
+		if got := *cfg.Hosts[i].Retries; got != want {
+			t.Errorf("host %s retries = %d, want %d", cfg.Hosts[i].Name, got, want)
+		}
+	}
+}
+
+func TestCheckHostRetries(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	ln.Close() // nothing listens: each attempt is refused
+	cfg, err := parseConfig([]byte(fmt.Sprintf(`retries: 2
+retry_backoff: 0.01
+hosts:
+  - {name: default, host: 127.0.0.1, type: tcp, port: %[1]s}
+  - {name: off, host: 127.0.0.1, type: tcp, port: %[1]s, retries: 0}
+`, port)))
+	if err != nil {
+		t.Fatalf("parseConfig: %v", err)
+	}
+	mon := newMonitor()
+	for i, want := range []int{3, 1} {
+		s := mon.checkHost(t.Context(), cfg.Hosts[i])
+		if s.Online || s.Failure != FailureRefused || s.Attempts != want {
+			t.Errorf("%s: online %v, failure %s, attempts %d; want refused after %d", s.Config.Name, s.Online, s.Failure, s.Attempts, want)
+		}
+	}
+}
+
+func TestResolveJumps(t *testing.T) {
+	sshConfig := writeSSHConfig(t, map[string]string{"config": `
+Host legacy
+    HostName 10.2.0.3
+    ProxyJump old-bastion
+
+Host old-bastion
+    HostName 198.51.100.1
+    User old
+
+Host edge
+    HostName edge.example.com
+    User edgeuser
+    ProxyJump gw@198.51.100.2:2200
+
+Host *
+    User me
+`})
+	hop := func(h HostConfig) string {
+		return h.User + "@" + h.Host + ":" + fmt.Sprint(h.Port)
+	}
+	tests := []struct {
+		name    string
+		hosts   string
+		host    string // whose chain to check
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "named host",
+			hosts: `  - {name: bastion, host: 203.0.113.1, user: ops, port: 2222}
+  - {name: app, host: 10.0.0.2, user: u, jump: [bastion]}`,
+			host: "app", want: []string{"ops@203.0.113.1:2222"},
+		},
+		{
+			name: "nested named hosts",
+			hosts: `  - {name: gateway, host: 203.0.113.9, user: gw}
+  - {name: bastion, host: 10.0.0.1, user: ops, jump: [gateway]}
+  - {name: app, host: 10.0.0.2, user: u, jump: [bastion]}`,
+			host: "app", want: []string{"gw@203.0.113.9:22", "ops@10.0.0.1:22"},
+		},
+		{
+			name:  "user@host:port",
+			hosts: `  - {name: app, host: 10.0.0.2, user: u, jump: ["jumper@192.0.2.7:2022", "192.0.2.8"]}`,
+			host:  "app", want: []string{"jumper@192.0.2.7:2022", "me@192.0.2.8:22"},
+		},
+		{
+			name:  "spec with its own ProxyJump",
+			hosts: `  - {name: app, host: 10.0.0.2, user: u, jump: [edge]}`,
+			host:  "app", want: []string{"gw@198.51.100.2:2200", "edgeuser@edge.example.com:22"},
+		},
+		{
+			name:  "ProxyJump from ssh config",
+			hosts: `  - {name: legacy, host: legacy, user: u}`,
+			host:  "legacy", want: []string{"old@198.51.100.1:22"},
+		},
+		{
+			name: "jump overrides ProxyJump",
+			hosts: `  - {name: bastion, host: 203.0.113.1, user: ops}
+  - {name: legacy, host: legacy, user: u, jump: [bastion]}`,
+			host: "legacy", want: []string{"ops@203.0.113.1:22"},
+		},
+		{
+			name:    "self",
+			hosts:   `  - {name: app, host: 10.0.0.2, jump: [app]}`,
+			wantErr: `host "app": jump loop through "app"`,
+		},
+		{
+			name: "loop",
+			hosts: `  - {name: a, host: 10.0.0.1, jump: [b]}
+  - {name: b, host: 10.0.0.2, jump: [c]}
+  - {name: c, host: 10.0.0.3, jump: [a]}`,
+			wantErr: "jump loop through",
+		},
+		{
+			name:    "non-ssh host",
+			hosts:   `  - {name: web, type: http, host: 10.0.0.2, jump: [bastion]}`,
+			wantErr: "jump is only supported for ssh checks",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := parseConfig([]byte("ssh_config: " + sshConfig + "\nhosts:\n" + tt.hosts + "\n"))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseConfig error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseConfig: %v", err)
+			}
+			for _, h := range cfg.Hosts {
+				if h.Name != tt.host {
+					continue
+				}
+				var got []string
+				for _, j := range h.jumps {
+					got = append(got, hop(j))
+				}
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("%s jumps = %v, want %v", h.Name, got, tt.want)
+				}
+				for _, j := range h.jumps {
+					if len(j.jumps) != 0 || len(j.Jump) != 0 {
+						t.Errorf("hop %s kept its own jumps: %+v", hop(j), j)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ContainerConfig enables the Docker/Podman collector for a host.
+type ContainerConfig struct {
+	Enabled bool     `yaml:"enabled"`
+	Runtime string   `yaml:"runtime"` // docker, podman or auto (default)
+	Pinned  []string `yaml:"pinned"`  // containers that must be running and healthy
+}
+
+// ContainerStatus is one container as reported by docker/podman ps.
+type ContainerStatus struct {
+	Name     string `json:"name"`
+	Image    string `json:"image"`
+	State    string `json:"state"`            // running, exited, restarting, ...
+	Health   string `json:"health,omitempty"` // healthy, unhealthy, starting
+	Restarts int    `json:"restarts"`
+	Pinned   bool   `json:"pinned,omitempty"`
+}
+
+// containerProbe lists all containers, one JSON object per line for docker
+// and a single JSON array for podman, plus docker restart counts.
+func containerProbe(cc ContainerConfig) string {
+	if !cc.Enabled {
+		return ""
+	}
+	rt := cc.Runtime
+	if rt == "" || rt == "auto" {
+		rt = "$(command -v docker >/dev/null && echo docker || echo podman)"
+	}
+	return `rt=` + rt + `
+echo "container_runtime=$rt"
+if [ "$rt" = docker ]; then
+	docker ps -a --format '{{json .}}' | sed 's/^/container=/'
+	ids=$(docker ps -aq)
+	[ -n "$ids" ] && docker inspect --format '{{.Name}} {{.RestartCount}}' $ids | sed 's/^/container_restarts=/'
+elif command -v podman >/dev/null; then
+	echo "containers_json=$(podman ps -a --format json | tr -d '\n')"
+fi
+`
+}
+
+// containers parses the container probe output and marks pinned containers.
+// Pinned containers that are missing are reported with state "missing".
+func (p probeResult) containers(cc ContainerConfig) []ContainerStatus {
+	if !cc.Enabled {
+		return nil
+	}
+	var out []ContainerStatus
+
+	// docker: {"Names":"web","Image":"nginx","State":"running","Status":"Up 2 hours (healthy)",...}
+	restarts := make(map[string]int)
+	for _, line := range p["container_restarts"] {
+		f := strings.Fields(line)
+		if len(f) == 2 {
+			restarts[strings.TrimPrefix(f[0], "/")], _ = strconv.Atoi(f[1])
+		}
+	}
+	for _, line := range p["container"] {
+		var d struct{ Names, Image, State, Status string }
+		if json.Unmarshal([]byte(line), &d) != nil {
+			continue
+		}
+		name := dockerName(d.Names)
+		out = append(out, ContainerStatus{
+			Name:     name,
+			Image:    d.Image,
+			State:    d.State,
+			Health:   containerHealth(d.Status),
+			Restarts: restarts[name],
+		})
+	}
+
+	// podman: [{"Names":["web"],"Image":"...","State":"running","Status":"...","Restarts":0}]
+	if raw := p.get("containers_json"); raw != "" {
+		var list []struct {
+			Names    []string
+			Image    string
+			State    string
+			Status   string
+			Restarts int
+		}
+		if json.Unmarshal([]byte(raw), &list) == nil {
+			for _, d := range list {
+				name := ""
+				if len(d.Names) > 0 {
+					name = d.Names[0]
+				}
+				out = append(out, ContainerStatus{
+					Name:     name,
+					Image:    d.Image,
+					State:    strings.ToLower(d.State),
+					Health:   containerHealth(d.Status),
+					Restarts: d.Restarts,
+				})
+			}
+		}
+	}
+
+	found := make(map[string]bool)
+	for i := range out {
+		for _, name := range cc.Pinned {
+			if out[i].Name == name {
+				out[i].Pinned = true
+				found[name] = true
+			}
+		}
+	}
+	for _, name := range cc.Pinned {
+		if !found[name] {
+			out = append(out, ContainerStatus{Name: name, State: "missing", Pinned: true})
+		}
+	}
+	return out
+}
+
+// dockerName picks a container's own name from docker's Names column, which
+// also lists the aliases other containers link to it by, e.g.
+// "web,app/web,worker/web".
+func dockerName(names string) string {
+	list := strings.Split(names, ",")
+	for _, n := range list {
+		if !strings.Contains(n, "/") {
+			return n
+		}
+	}
+	return list[0]
+}
+
+// containerHealth extracts the healthcheck state from a ps Status column
+// such as "Up 2 hours (healthy)" or "Up 5 seconds (health: starting)".
+func containerHealth(status string) string {
+	switch {
+	case strings.Contains(status, "(unhealthy)"):
+		return "unhealthy"
+	case strings.Contains(status, "(healthy)"):
+		return "healthy"
+	case strings.Contains(status, "health: starting"):
+		return "starting"
+	default:
+		return ""
+	}
+}
+
+// health is the effect of the container on its host: only pinned
+// containers affect host health.
+func (c ContainerStatus) health() Health {
+	switch {
+	case c.State != "running" || c.Health == "unhealthy":
+		return HealthCritical
+	case c.Health == "starting":
+		return HealthWarning
+	default:
+		return HealthOK
+	}
+}
+
+// describe renders the container state for display.
+func (c ContainerStatus) describe() string {
+	d := c.State
+	if c.Health != "" {
+		d += " (" + c.Health + ")"
+	}
+	if c.Restarts > 0 {
+		d += fmt.Sprintf(", %d restarts", c.Restarts)
+	}
+	return d
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProbeContainers(t *testing.T) {
+	tests := []struct {
+		name   string
+		cc     ContainerConfig
+		output []string
+		want   []ContainerStatus
+	}{
+		{
+			name: "disabled",
+			output: []string{
+				`container={"Names":"web","Image":"nginx","State":"running","Status":"Up 2 hours"}`,
+			},
+		},
+		{
+			name: "docker",
+			cc:   ContainerConfig{Enabled: true, Pinned: []string{"web", "db", "cache"}},
+			output: []string{
+				"container_runtime=docker",
+				`container={"Command":"\"nginx -g…\"","ID":"3f2a","Image":"nginx:1.25","Names":"web,app/web","State":"running","Status":"Up 2 hours (healthy)"}`,
+				`container={"ID":"8c1d","Image":"postgres:16","Names":"db","State":"restarting","Status":"Restarting (1) 5 seconds ago"}`,
+				`container={"ID":"91aa","Image":"myapp:latest","Names":"app","State":"running","Status":"Up 5 seconds (health: starting)"}`,
+				`container={"ID":"0bad","Image":"busybox","Names":"old","State":"exited","Status":"Exited (0) 3 days ago"}`,
+				"container=not json",
+				"container_restarts=/web 0",
+				"container_restarts=/db 14",
+				"container_restarts=/app 1",
+				"container_restarts=/old 0",
+			},
+			want: []ContainerStatus{
+				{Name: "web", Image: "nginx:1.25", State: "running", Health: "healthy", Pinned: true},
+				{Name: "db", Image: "postgres:16", State: "restarting", Restarts: 14, Pinned: true},
+				{Name: "app", Image: "myapp:latest", State: "running", Health: "starting", Restarts: 1},
+				{Name: "old", Image: "busybox", State: "exited"},
+				{Name: "cache", State: "missing", Pinned: true},
+			},
+		},
+		{
+			name: "podman",
+			cc:   ContainerConfig{Enabled: true, Runtime: "podman", Pinned: []string{"web"}},
+			output: []string{
+				"container_runtime=podman",
+				`containers_json=[{"Names":["web"],"Image":"docker.io/library/nginx:1.25","State":"running","Status":"Up 2 hours (unhealthy)","Restarts":3},` +
+					`{"Names":["job"],"Image":"localhost/job:1","State":"Exited","Status":"Exited (1) 1 hour ago","Restarts":0}]`,
+			},
+			want: []ContainerStatus{
+				{Name: "web", Image: "docker.io/library/nginx:1.25", State: "running", Health: "unhealthy", Restarts: 3, Pinned: true},
+				{Name: "job", Image: "localhost/job:1", State: "exited"},
+			},
+		},
+		{
+			name:   "no runtime",
+			cc:     ContainerConfig{Enabled: true, Pinned: []string{"web"}},
+			output: []string{"container_runtime=podman"},
+			want:   []ContainerStatus{{Name: "web", State: "missing", Pinned: true}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := parseProbe(probeOutput(append(tt.output, "probe_end=1")...))
+			if err != nil {
+				t.Fatalf("parseProbe: %v", err)
+			}
+			if got := p.containers(tt.cc); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("containers =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContainerHealth(t *testing.T) {
+	tests := []struct {
+		c    ContainerStatus
+		want Health
+		desc string
+	}{
+		{ContainerStatus{State: "running"}, HealthOK, "running"},
+		{ContainerStatus{State: "running", Health: "healthy", Restarts: 2}, HealthOK, "running (healthy), 2 restarts"},
+		{ContainerStatus{State: "running", Health: "starting"}, HealthWarning, "running (starting)"},
+		{ContainerStatus{State: "running", Health: "unhealthy"}, HealthCritical, "running (unhealthy)"},
+		{ContainerStatus{State: "exited"}, HealthCritical, "exited"},
+		{ContainerStatus{State: "missing"}, HealthCritical, "missing"},
+	}
+	for _, tt := range tests {
+		if got := tt.c.health(); got != tt.want {
+			t.Errorf("%+v health = %s, want %s", tt.c, got, tt.want)
+		}
+		if got := tt.c.describe(); got != tt.desc {
+			t.Errorf("%+v describe = %q, want %q", tt.c, got, tt.desc)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// CustomCheck is a user-defined remote command evaluated on each cycle. With
+// neither Match nor Extract it passes when the exit code equals ExpectExit.
+type CustomCheck struct {
+	Name       string    `yaml:"name"`
+	Command    string    `yaml:"command"`
+	ExpectExit *int      `yaml:"expect_exit"` // default 0
+	Match      string    `yaml:"match"`       // regex the output must match
+	Extract    string    `yaml:"extract"`     // regex capturing a number (first group, or whole match)
+	Threshold  Threshold `yaml:",inline"`     // warn/crit for the extracted number
+	Severity   Health    `yaml:"severity"`    // health when exit/match fails (default critical)
+	Timeout    int       `yaml:"timeout"`     // seconds before the command is killed (default 10)
+
+	matchRe   *regexp.Regexp
+	extractRe *regexp.Regexp
+}
+
+// CheckResult is the outcome of one CustomCheck.
+type CheckResult struct {
+	Name    string   `json:"name"`
+	Health  Health   `json:"health"`
+	Value   *float64 `json:"value,omitempty"`
+	Message string   `json:"message,omitempty"`
+	Output  string   `json:"output,omitempty"`
+}
+
+// defaultCheckTimeout bounds each check well inside probeTimeout, so one
+// hung command does not cost the rest of the probe.
+const defaultCheckTimeout = 10
+
+// compile validates the check and prepares its regexes.
+func (c *CustomCheck) compile() error {
+	if c.Name == "" || c.Command == "" {
+		return fmt.Errorf("custom check needs a name and a command")
+	}
+	var err error
+	if c.Match != "" {
+		if c.matchRe, err = regexp.Compile(c.Match); err != nil {
+			return fmt.Errorf("check %q: match: %w", c.Name, err)
+		}
+	}
+	if c.Extract != "" {
+		if c.extractRe, err = regexp.Compile(c.Extract); err != nil {
+			return fmt.Errorf("check %q: extract: %w", c.Name, err)
+		}
+	}
+	switch c.Severity {
+	case "":
+		c.Severity = HealthCritical
+	case HealthWarning, HealthCritical:
+	default:
+		return fmt.Errorf("check %q: severity must be warning or critical", c.Name)
+	}
+	switch {
+	case c.Timeout < 0:
+		return fmt.Errorf("check %q: timeout must be positive", c.Name)
+	case c.Timeout == 0:
+		c.Timeout = defaultCheckTimeout
+	}
+	return nil
+}
+
+// mergeChecks returns the global checks followed by the host's, with host
+// checks replacing global ones of the same name.
+func mergeChecks(global, host []CustomCheck) []CustomCheck {
+	var out []CustomCheck
+	override := make(map[string]bool)
+	for _, c := range host {
+		override[c.Name] = true
+	}
+	for _, c := range global {
+		if !override[c.Name] {
+			out = append(out, c)
+		}
+	}
+	return append(out, host...)
+}
+
+// evaluate turns a command's exit code and output into a result.
+func (c CustomCheck) evaluate(rc int, out string) CheckResult {
+	res := CheckResult{Name: c.Name, Health: HealthOK, Output: truncate(strings.TrimSpace(out), 200)}
+	fail := func(msg string) {
+		res.Health = c.Severity
+		res.Message = msg
+	}
+
+	want := 0
+	if c.ExpectExit != nil {
+		want = *c.ExpectExit
+	}
+	if (c.ExpectExit != nil || (c.matchRe == nil && c.extractRe == nil)) && rc != want {
+		fail(fmt.Sprintf("exit %d, want %d", rc, want))
+		return res
+	}
+	if c.matchRe != nil && !c.matchRe.MatchString(out) {
+		fail(fmt.Sprintf("output does not match %q", c.Match))
+		return res
+	}
+	if c.extractRe != nil {
+		m := c.extractRe.FindStringSubmatch(out)
+		if m == nil {
+			fail("no value found")
+			return res
+		}
+		s := m[0]
+		if len(m) > 1 {
+			s = m[1]
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			fail(fmt.Sprintf("not a number: %q", s))
+			return res
+		}
+		res.Value = &v
+		res.Health = c.Threshold.level(v)
+		switch res.Health {
+		case HealthCritical:
+			res.Message = fmt.Sprintf("%g ≥ crit %g", v, c.Threshold.Crit)
+		case HealthWarning:
+			res.Message = fmt.Sprintf("%g ≥ warn %g", v, c.Threshold.Warn)
+		}
+	}
+	return res
+}
+
+// customProbe emits a probe section running each check via sh -c so a broken
+// command cannot break the rest of the script, nor one reading stdin eat it.
+// Where timeout(1) exists each check is killed after its Timeout.
+func customProbe(checks []CustomCheck) string {
+	if len(checks) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("killer=$(command -v timeout || command -v gtimeout)\n")
+	for i, c := range checks {
+		fmt.Fprintf(&b, "out=$(${killer:+\"$killer\" %d} sh -c %s </dev/null 2>&1); rc=$?\n", c.Timeout, shellQuote(c.Command))
+		fmt.Fprintf(&b, "[ -n \"$killer\" ] && [ $rc = 124 ] && echo check.%d.timed_out=1\n", i)
+		fmt.Fprintf(&b, "echo \"check.%d.rc=$rc\"\n", i)
+		fmt.Fprintf(&b, "printf '%%s\\n' \"$out\" | sed 's/^/check.%d.out=/'\n", i)
+	}
+	return b.String()
+}
+
+// customResults evaluates every check against the parsed probe output.
+func (p probeResult) customResults(checks []CustomCheck) []CheckResult {
+	var results []CheckResult
+	for i, c := range checks {
+		rc, err := strconv.Atoi(p.get(fmt.Sprintf("check.%d.rc", i)))
+		if err != nil {
+			results = append(results, CheckResult{Name: c.Name, Health: HealthUnknown, Message: "no result"})
+			continue
+		}
+		if p.get(fmt.Sprintf("check.%d.timed_out", i)) == "1" {
+			results = append(results, CheckResult{Name: c.Name, Health: c.Severity, Message: fmt.Sprintf("timed out after %ds", c.Timeout)})
+			continue
+		}
+		out := strings.Join(p[fmt.Sprintf("check.%d.out", i)], "\n")
+		results = append(results, c.evaluate(rc, out))
+	}
+	return results
+}
+
+// shellQuote wraps s in single quotes for POSIX sh.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCustomCheckEvaluate(t *testing.T) {
+	two := 2
+	tests := []struct {
+		name    string
+		check   CustomCheck
+		rc      int
+		out     string
+		health  Health
+		value   float64 // checked unless noValue
+		noValue bool
+		message string // substring
+	}{
+		{
+			name:    "exit zero passes",
+			check:   CustomCheck{Command: "true"},
+			health:  HealthOK,
+			noValue: true,
+		},
+		{
+			name:    "non-zero exit fails",
+			check:   CustomCheck{Command: "false"},
+			rc:      1,
+			health:  HealthCritical,
+			noValue: true,
+			message: "exit 1, want 0",
+		},
+		{
+			name:    "non-zero exit uses severity",
+			check:   CustomCheck{Command: "false", Severity: HealthWarning},
+			rc:      3,
+			health:  HealthWarning,
+			noValue: true,
+			message: "exit 3",
+		},
+		{
+			name:    "expected non-zero exit",
+			check:   CustomCheck{Command: "grep", ExpectExit: &two},
+			rc:      2,
+			health:  HealthOK,
+			noValue: true,
+		},
+		{
+			name:    "match ignores exit without expect_exit",
+			check:   CustomCheck{Command: "x", Match: `active \(running\)`},
+			rc:      3,
+			out:     "Active: active (running) since Mon",
+			health:  HealthOK,
+			noValue: true,
+		},
+		{
+			name:    "match fails",
+			check:   CustomCheck{Command: "x", Match: `^ok$`},
+			out:     "degraded",
+			health:  HealthCritical,
+			noValue: true,
+			message: "does not match",
+		},
+		{
+			name:    "expect_exit checked before match",
+			check:   CustomCheck{Command: "x", Match: `ok`, ExpectExit: new(int)},
+			rc:      1,
+			out:     "ok",
+			health:  HealthCritical,
+			noValue: true,
+			message: "exit 1",
+		},
+		{
+			name:   "extract below thresholds",
+			check:  CustomCheck{Command: "x", Extract: `queue=(\d+)`, Threshold: Threshold{Warn: 10, Crit: 100}},
+			out:    "queue=4\n",
+			health: HealthOK,
+			value:  4,
+		},
+		{
+			name:    "extract at warn",
+			check:   CustomCheck{Command: "x", Extract: `queue=(\d+)`, Threshold: Threshold{Warn: 10, Crit: 100}},
+			out:     "queue=10",
+			health:  HealthWarning,
+			value:   10,
+			message: "warn 10",
+		},
+		{
+			name:    "extract over crit",
+			check:   CustomCheck{Command: "x", Extract: `queue=(\d+)`, Threshold: Threshold{Warn: 10, Crit: 100}},
+			out:     "queue=250",
+			health:  HealthCritical,
+			value:   250,
+			message: "crit 100",
+		},
+		{
+			name:   "extract whole match",
+			check:  CustomCheck{Command: "x", Extract: `[0-9.]+`, Threshold: Threshold{Warn: 1}},
+			out:    "took 0.25s",
+			health: HealthOK,
+			value:  0.25,
+		},
+		{
+			name:    "extract finds nothing",
+			check:   CustomCheck{Command: "x", Extract: `queue=(\d+)`},
+			out:     "error",
+			health:  HealthCritical,
+			noValue: true,
+			message: "no value found",
+		},
+		{
+			name:    "extract not a number",
+			check:   CustomCheck{Command: "x", Extract: `queue=(\S+)`},
+			out:     "queue=many",
+			health:  HealthCritical,
+			noValue: true,
+			message: "not a number",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.check
+			c.Name = "test"
+			if err := c.compile(); err != nil {
+				t.Fatalf("compile: %v", err)
+			}
+			res := c.evaluate(tt.rc, tt.out)
+			if res.Health != tt.health {
+				t.Errorf("health = %s (%s), want %s", res.Health, res.Message, tt.health)
+			}
+			switch {
+			case tt.noValue && res.Value != nil:
+				t.Errorf("value = %v, want none", *res.Value)
+			case !tt.noValue && (res.Value == nil || *res.Value != tt.value):
+				t.Errorf("value = %v, want %v", res.Value, tt.value)
+			}
+			if !strings.Contains(res.Message, tt.message) {
+				t.Errorf("message = %q, want it to contain %q", res.Message, tt.message)
+			}
+		})
+	}
+}
+
+func TestCustomCheckCompile(t *testing.T) {
+	tests := []struct {
+		name    string
+		check   CustomCheck
+		wantErr string
+	}{
+		{"valid", CustomCheck{Name: "a", Command: "true"}, ""},
+		{"no name", CustomCheck{Command: "true"}, "needs a name"},
+		{"no command", CustomCheck{Name: "a"}, "needs a name"},
+		{"bad match", CustomCheck{Name: "a", Command: "x", Match: "("}, "match"},
+		{"bad extract", CustomCheck{Name: "a", Command: "x", Extract: "["}, "extract"},
+		{"bad severity", CustomCheck{Name: "a", Command: "x", Severity: HealthOK}, "severity"},
+		{"negative timeout", CustomCheck{Name: "a", Command: "x", Timeout: -1}, "timeout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.check
+			err := c.compile()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("compile: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("compile error = %v, want %q", err, tt.wantErr)
+			case err == nil && c.Severity != HealthCritical:
+				t.Errorf("default severity = %s, want critical", c.Severity)
+			case err == nil && c.Timeout != defaultCheckTimeout:
+				t.Errorf("default timeout = %d, want %d", c.Timeout, defaultCheckTimeout)
+			}
+		})
+	}
+}
+
+func TestMergeChecks(t *testing.T) {
+	global := []CustomCheck{{Name: "disk", Command: "g1"}, {Name: "ntp", Command: "g2"}}
+	host := []CustomCheck{{Name: "ntp", Command: "h1"}, {Name: "app", Command: "h2"}}
+	got := mergeChecks(global, host)
+	var cmds []string
+	for _, c := range got {
+		cmds = append(cmds, c.Name+"="+c.Command)
+	}
+	if want := "disk=g1 ntp=h1 app=h2"; strings.Join(cmds, " ") != want {
+		t.Errorf("mergeChecks = %v, want %s", cmds, want)
+	}
+	if got := mergeChecks(global, nil); len(got) != 2 {
+		t.Errorf("mergeChecks without host checks = %v", got)
+	}
+}
+
+func TestConfigCheckOverrides(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cfg, err := parseConfig([]byte(`
+checks:
+  - {name: ntp, command: chronyc tracking, match: Normal}
+  - {name: queue, command: cat /var/spool/q, extract: '(\d+)', warn: 10}
+hosts:
+  - {host: a.example}
+  - host: b.example
+    checks:
+      - {name: queue, command: cat /srv/q, extract: '(\d+)', warn: 50}
+  - {host: c.example, type: tcp, port: 80}
+`))
+	if err != nil {
+		t.Fatalf("parseConfig: %v", err)
+	}
+	a, b, c := cfg.Hosts[0], cfg.Hosts[1], cfg.Hosts[2]
+	if len(a.Checks) != 2 || a.Checks[1].Threshold.Warn != 10 {
+		t.Errorf("host a checks = %+v, want the global ones", a.Checks)
+	}
+	if len(b.Checks) != 2 || b.Checks[1].Command != "cat /srv/q" || b.Checks[1].Threshold.Warn != 50 {
+		t.Errorf("host b checks = %+v, want queue overridden", b.Checks)
+	}
+	if b.Checks[1].extractRe == nil || b.Checks[0].matchRe == nil {
+		t.Errorf("host b checks not compiled")
+	}
+	if len(c.Checks) != 0 {
+		t.Errorf("tcp host got checks %+v", c.Checks)
+	}
+	if res := b.Checks[1].evaluate(0, "20"); res.Health != HealthOK {
+		t.Errorf("host b queue=20 = %s, want ok under its own threshold", res.Health)
+	}
+
+	if _, err := parseConfig([]byte("hosts:\n  - {host: d, type: tcp, port: 1, checks: [{name: x, command: y}]}\n")); err == nil {
+		t.Errorf("checks on a tcp host: want error")
+	}
+}
+
+func TestCustomProbeStdin(t *testing.T) {
+	checks := []CustomCheck{{Name: "reads", Command: "cat"}, {Name: "after", Command: "echo ok"}}
+	pad := strings.Repeat("echo pad=1\n", 10000)
+	out, err := runLocalScript(t.Context(), script(customProbe(checks), pad))
+	if err != nil {
+		t.Fatalf("runLocalScript: %v", err)
+	}
+	p, err := parseProbe(out)
+	if err != nil {
+		t.Fatalf("parseProbe: %v", err)
+	}
+	res := p.customResults(checks)
+	if res[1].Health != HealthOK || res[1].Output != "ok" || !p.complete() {
+		t.Errorf("results = %+v, complete %v", res, p.complete())
+	}
+}
+
+func TestCustomProbeTimeout(t *testing.T) {
+	if _, err := exec.LookPath("timeout"); err != nil {
+		t.Skip("no timeout(1) on this system")
+	}
+	checks := []CustomCheck{
+		{Name: "hung", Command: "echo started; sleep 10", Timeout: 1, Severity: HealthWarning},
+		{Name: "after", Command: "echo ok", Timeout: 1},
+	}
+	start := time.Now()
+	out, err := runLocalScript(t.Context(), script(customProbe(checks)))
+	if err != nil {
+		t.Fatalf("runLocalScript: %v", err)
+	}
+	if took := time.Since(start); took > 5*time.Second {
+		t.Errorf("probe took %s, want the hung check cut off after 1s", took)
+	}
+	p, err := parseProbe(out)
+	if err != nil {
+		t.Fatalf("parseProbe: %v", err)
+	}
+	res := p.customResults(checks)
+	if res[0].Health != HealthWarning || res[0].Message != "timed out after 1s" {
+		t.Errorf("hung check = %+v, want a warning that it timed out", res[0])
+	}
+	if res[1].Health != HealthOK || res[1].Output != "ok" || !p.complete() {
+		t.Errorf("results = %+v, complete %v", res, p.complete())
+	}
+}
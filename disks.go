@@ -0,0 +1,209 @@
+package main
+
+import (
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DiskConfig selects which mounts are monitored and when a fill-rate
+// forecast raises an alert.
+type DiskConfig struct {
+	Include       []string `yaml:"include"`         // mount globs to keep (default: all real filesystems)
+	Exclude       []string `yaml:"exclude"`         // mount globs to drop
+	FillWarnHours float64  `yaml:"fill_warn_hours"` // warn if forecast full within this many hours
+	FillCritHours float64  `yaml:"fill_crit_hours"`
+}
+
+var defaultDiskConfig = DiskConfig{FillWarnHours: 72, FillCritHours: 24}
+
+// merge layers the non-zero fields of o on top of d.
+func (d DiskConfig) merge(o DiskConfig) DiskConfig {
+	if len(o.Include) > 0 {
+		d.Include = o.Include
+	}
+	if len(o.Exclude) > 0 {
+		d.Exclude = o.Exclude
+	}
+	if o.FillWarnHours != 0 {
+		d.FillWarnHours = o.FillWarnHours
+	}
+	if o.FillCritHours != 0 {
+		d.FillCritHours = o.FillCritHours
+	}
+	return d
+}
+
+// wants reports whether mount passes the include/exclude filters.
+func (d DiskConfig) wants(mount string) bool {
+	for _, p := range d.Exclude {
+		if ok, _ := path.Match(p, mount); ok {
+			return false
+		}
+	}
+	if len(d.Include) == 0 {
+		return true
+	}
+	for _, p := range d.Include {
+		if ok, _ := path.Match(p, mount); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// filter drops mounts excluded by the host's disk config.
+func (d DiskConfig) filter(disks []DiskUsage) []DiskUsage {
+	var out []DiskUsage
+	for _, du := range disks {
+		if d.wants(du.Mount) {
+			out = append(out, du)
+		}
+	}
+	return out
+}
+
+// realFilesystem reports whether a mount of dev is worth monitoring. Pseudo
+// filesystems (tmpfs, overlay, devfs...) have no "/" in their source and are
+// skipped, as are loop devices and macOS system volumes.
+func realFilesystem(dev, mount string) bool {
+	return strings.Contains(dev, "/") && !strings.HasPrefix(dev, "/dev/loop") &&
+		(!strings.HasPrefix(mount, "/System/Volumes/") || mount == "/System/Volumes/Data")
+}
+
+// parseDF combines `df -Pk` and `df -Pi` output into one row per real
+// filesystem, skipping repeated (bind) mounts of the same device.
+func parseDF(blocks, inodes []string) []DiskUsage {
+	type inodeCount struct{ used, total uint64 }
+	inodeByMount := make(map[string]inodeCount)
+	if len(inodes) > 1 {
+		header := strings.Fields(inodes[0])
+		usedCol, freeCol, mountCol := -1, -1, -1
+		for i, h := range header {
+			switch strings.ToLower(h) {
+			case "iused":
+				usedCol = i
+			case "ifree":
+				freeCol = i
+			case "mounted":
+				mountCol = i
+			}
+		}
+		for _, line := range inodes[1:] {
+			f := strings.Fields(line)
+			if usedCol < 0 || freeCol < 0 || mountCol < 0 || len(f) <= mountCol {
+				break
+			}
+			used, err1 := strconv.ParseUint(f[usedCol], 10, 64)
+			free, err2 := strconv.ParseUint(f[freeCol], 10, 64)
+			if err1 == nil && err2 == nil {
+				inodeByMount[strings.Join(f[mountCol:], " ")] = inodeCount{used, used + free}
+			}
+		}
+	}
+
+	var disks []DiskUsage
+	seen := make(map[string]bool)
+	for i, line := range blocks {
+		f := strings.Fields(line)
+		if i == 0 || len(f) < 6 {
+			continue
+		}
+		dev, mount := f[0], strings.Join(f[5:], " ")
+		total, err1 := strconv.ParseUint(f[1], 10, 64)
+		used, err2 := strconv.ParseUint(f[2], 10, 64)
+		free, err3 := strconv.ParseUint(f[3], 10, 64)
+		if err1 != nil || err2 != nil || err3 != nil || total == 0 || seen[dev] {
+			continue
+		}
+		if !realFilesystem(dev, mount) {
+			continue
+		}
+		seen[dev] = true
+		ic := inodeByMount[mount]
+		disks = append(disks, DiskUsage{
+			Mount:       mount,
+			Device:      dev,
+			Used:        used * 1024,
+			Total:       total * 1024,
+			Free:        free * 1024,
+			InodesUsed:  ic.used,
+			InodesTotal: ic.total,
+		})
+	}
+	return disks
+}
+
+// Forecast tuning: samples closer together than diskSampleEvery are not
+// kept, and only the last diskWindow of history feeds the trend. Freeing
+// more than diskCleanup of a mount's size starts its history over, so
+// growth from before a cleanup does not linger in the forecast.
+const (
+	diskSampleEvery = time.Minute
+	diskWindow      = 24 * time.Hour
+	diskMinSpan     = 10 * time.Minute
+	diskCleanup     = 0.01
+)
+
+type diskSample struct {
+	at   time.Time
+	used float64
+}
+
+// diskTrends remembers recent usage per host and mount to forecast when
+// each mount will fill.
+type diskTrends struct {
+	mu      sync.Mutex
+	samples map[string][]diskSample // "host\x00mount" -> samples, oldest first
+}
+
+func newDiskTrends() *diskTrends {
+	return &diskTrends{samples: make(map[string][]diskSample)}
+}
+
+// forecast records the current usage of each disk and sets FullIn from a
+// least-squares fit of used bytes over the retained window.
+func (t *diskTrends) forecast(host string, now time.Time, disks []DiskUsage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := range disks {
+		d := &disks[i]
+		key := host + "\x00" + d.Mount
+		s := t.samples[key]
+		if len(s) > 0 && s[len(s)-1].used-float64(d.Used) > diskCleanup*float64(d.Total) {
+			s = nil
+		}
+		if len(s) == 0 || now.Sub(s[len(s)-1].at) >= diskSampleEvery {
+			s = append(s, diskSample{now, float64(d.Used)})
+		}
+		for len(s) > 0 && now.Sub(s[0].at) > diskWindow {
+			s = s[1:]
+		}
+		t.samples[key] = s
+
+		if len(s) < 3 || s[len(s)-1].at.Sub(s[0].at) < diskMinSpan {
+			continue
+		}
+		// slope in bytes per second
+		var sx, sy, sxx, sxy float64
+		n := float64(len(s))
+		for _, p := range s {
+			x := p.at.Sub(s[0].at).Seconds()
+			sx += x
+			sy += p.used
+			sxx += x * x
+			sxy += x * p.used
+		}
+		den := n*sxx - sx*sx
+		if den == 0 {
+			continue
+		}
+		slope := (n*sxy - sx*sy) / den
+		if slope <= 0 {
+			continue
+		}
+		d.FullIn = time.Duration(float64(d.Free) / slope * float64(time.Second))
+	}
+}
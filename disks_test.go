@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDiskForecast(t *testing.T) {
+	const gib = 1 << 30
+	tests := []struct {
+		name    string
+		used    func(i int) float64 // GiB used at sample i, taken every 10 minutes
+		n       int
+		wantMin time.Duration
+		wantMax time.Duration // 0: no forecast
+	}{
+		{name: "flat", used: func(int) float64 { return 40 }, n: 18},
+		{name: "shrinking", used: func(i int) float64 { return 40 - float64(i)/6 }, n: 18},
+		{name: "too little history", used: func(i int) float64 { return 40 + float64(i) }, n: 2},
+		// 1 GiB an hour with 57 GiB left at the end.
+		{name: "growing", used: func(i int) float64 { return 40 + float64(i)/6 }, n: 19, wantMin: 56*time.Hour + 59*time.Minute, wantMax: 57*time.Hour + time.Minute},
+		// Ten hours of slow growth, then a few GiB freed and nothing since.
+		{name: "flat after a cleanup", used: func(i int) float64 {
+			if i < 60 {
+				return 40 + float64(i)/6
+			}
+			return 45
+		}, n: 66},
+		{name: "just cleaned up", used: func(i int) float64 {
+			if i < 12 {
+				return 40 + float64(i)
+			}
+			return 20 + float64(i-12)/6
+		}, n: 14},
+		// Growth before the cleanup does not count: 1 GiB an hour after it.
+		{name: "growing after a cleanup", used: func(i int) float64 {
+			if i < 12 {
+				return 40 + float64(i)
+			}
+			return 20 + float64(i-12)/6
+		}, n: 18, wantMin: 79 * time.Hour, wantMax: 80 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trends := &diskTrends{samples: make(map[string][]diskSample)}
+			start := time.Now()
+			var d DiskUsage
+			for i := range tt.n {
+				used := uint64(tt.used(i) * gib)
+				disks := []DiskUsage{{Mount: "/data", Used: used, Total: 100 * gib, Free: 100*gib - used}}
+				trends.forecast("nas", start.Add(time.Duration(i)*10*time.Minute), disks)
+				d = disks[0]
+			}
+			if tt.wantMax == 0 {
+				if d.FullIn != 0 {
+					t.Errorf("FullIn = %s, want no forecast", d.FullIn)
+				}
+				return
+			}
+			if d.FullIn < tt.wantMin || d.FullIn > tt.wantMax {
+				t.Errorf("FullIn = %s, want between %s and %s", d.FullIn, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
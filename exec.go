@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// execResult is the outcome of `pulse exec` on one host.
+type execResult struct {
+	ID       string        `json:"id"`
+	Name     string        `json:"name"`
+	Host     string        `json:"host"`
+	ExitCode int           `json:"exit_code"` // -1 when the command did not finish
+	Output   string        `json:"output,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Failure  FailureReason `json:"failure,omitempty"`
+	Seconds  float64       `json:"seconds"`
+}
+
+// execHosts returns the hosts `pulse exec` can run on (ssh and local)
+// whose name or label matches one of the globs, or all of them when no
+// globs are given.
+func execHosts(cfg *Config, globs []string) []HostConfig {
+	var out []HostConfig
+	for _, hc := range cfg.Hosts {
+		if hc.Type != "ssh" && hc.Type != "local" {
+			continue
+		}
+		match := len(globs) == 0
+		for _, g := range globs {
+			a, _ := path.Match(g, hc.Name)
+			b, _ := path.Match(g, hc.Label)
+			match = match || a || b
+		}
+		if match {
+			out = append(out, hc)
+		}
+	}
+	return out
+}
+
+// execAll runs cmd on every host at once, at most cfg.concurrency() at a
+// time, giving the command timeout to finish on each. Host i writes its
+// output to out[i]. Results keep the order of hosts.
+func execAll(ctx context.Context, cfg *Config, hosts []HostConfig, cmd string, timeout time.Duration, out []*lineWriter) []execResult {
+	results := make([]execResult, len(hosts))
+	sem := make(chan struct{}, cfg.concurrency())
+	var wg sync.WaitGroup
+	for i, hc := range hosts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+			}
+			results[i] = execOne(ctx, hc, cmd, timeout, out[i])
+			out[i].Flush()
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// execOne runs cmd on hc over a fresh SSH connection, or through sh for a
+// local host. Connecting is bounded by the host's own timeout, the command
+// by timeout.
+func execOne(ctx context.Context, hc HostConfig, cmd string, timeout time.Duration, out io.Writer) execResult {
+	res := execResult{ID: hc.Name, Name: hc.Label, Host: hc.Host, ExitCode: -1}
+	start := time.Now()
+
+	var client *ssh.Client
+	var err error
+	if hc.Type != "local" {
+		client, err = sshConnect(ctx, hc)
+	}
+	if err == nil {
+		rctx, cancel := context.WithTimeout(ctx, timeout)
+		if client != nil {
+			err = runCommand(rctx, client, cmd, out)
+			client.Close()
+		} else {
+			c := exec.CommandContext(rctx, "sh", "-c", cmd)
+			c.Stdout, c.Stderr = out, out
+			c.WaitDelay = time.Second
+			if err = c.Run(); rctx.Err() != nil {
+				err = rctx.Err()
+			}
+		}
+		if rctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("timed out after %s: %w", timeout, err)
+		}
+		cancel()
+	}
+	res.Seconds = time.Since(start).Seconds()
+
+	var sshExit *ssh.ExitError
+	var localExit *exec.ExitError
+	switch {
+	case err == nil:
+		res.ExitCode = 0
+	case errors.As(err, &sshExit):
+		res.ExitCode = sshExit.ExitStatus()
+	case errors.As(err, &localExit) && localExit.Exited():
+		res.ExitCode = localExit.ExitCode()
+	default:
+		res.Error = err.Error()
+		res.Failure = classifyError(err)
+	}
+	return res
+}
+
+// lineWriter prefixes each complete line written to it before passing it
+// on, holding mu so lines from parallel hosts never interleave mid-line.
+type lineWriter struct {
+	mu     *sync.Mutex
+	dst    io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		fmt.Fprintf(w.dst, "%s%s", w.prefix, w.buf[:i+1])
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes out a final line that had no newline.
+func (w *lineWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) > 0 {
+		fmt.Fprintf(w.dst, "%s%s\n", w.prefix, w.buf)
+		w.buf = nil
+	}
+}
+
+// printExecSummary prints one row per host: exit code, time taken and any
+// error that kept the command from finishing.
+func printExecSummary(w io.Writer, results []execResult) {
+	fmt.Fprintf(w, "%-20s %5s %7s  %s\n", "HOST", "EXIT", "TIME", "ERROR")
+	for _, r := range results {
+		code := "-"
+		if r.ExitCode >= 0 {
+			code = fmt.Sprint(r.ExitCode)
+		}
+		detail := r.Error
+		if r.Failure != "" {
+			detail = string(r.Failure) + ": " + detail
+		}
+		line := fmt.Sprintf("%-20s %5s %6.1fs  %s", r.Name, code, r.Seconds, detail)
+		fmt.Fprintln(w, strings.TrimRight(line, " "))
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// FailureReason classifies why a check failed, independent of the raw
+// error text, so it can drive display and notification routing.
+type FailureReason string
+
+const (
+	FailureDNS         FailureReason = "dns_failure"        // name did not resolve
+	FailureRefused     FailureReason = "connection_refused" // nothing listening
+	FailureTimeout     FailureReason = "timeout"            // no answer in time
+	FailureUnreachable FailureReason = "unreachable"        // no route to host or network
+	FailureAuth        FailureReason = "auth_failed"        // credentials or certificate rejected
+	FailureHostKey     FailureReason = "host_key_mismatch"  // server key differs from the pinned one
+	FailureCommand     FailureReason = "command_failed"     // connected, but the probe or request failed
+	FailurePartial     FailureReason = "partial_data"       // probe output was incomplete
+	FailureMissed      FailureReason = "heartbeat_missed"   // no push within the grace period
+)
+
+// failureReasons lists every reason, for validating notify routes.
+var failureReasons = []FailureReason{
+	FailureDNS, FailureRefused, FailureTimeout, FailureUnreachable,
+	FailureAuth, FailureHostKey, FailureCommand, FailurePartial, FailureMissed,
+}
+
+// classifyError maps a check error to a FailureReason. Errors it cannot
+// place are reported as command_failed.
+func classifyError(err error) FailureReason {
+	var keyErr *HostKeyError
+	if errors.As(err, &keyErr) {
+		if keyErr.Unknown {
+			return FailureAuth // strict mode: the server could not be authenticated
+		}
+		return FailureHostKey
+	}
+	var dnsErr *net.DNSError
+	var certErr *tls.CertificateVerificationError
+	var netErr net.Error
+	msg := err.Error()
+	switch {
+	case errors.As(err, &dnsErr):
+		return FailureDNS
+	case errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ECONNRESET), strings.Contains(msg, "connection refused"), strings.Contains(msg, "Connection refused"):
+		return FailureRefused
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout(), strings.Contains(msg, "i/o timeout"):
+		return FailureTimeout
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return FailureUnreachable
+	case errors.As(err, &certErr), strings.Contains(msg, "unable to authenticate"), strings.Contains(msg, "no auth methods"):
+		return FailureAuth
+	}
+	var openErr *ssh.OpenChannelError
+	if errors.As(err, &openErr) && openErr.Reason == ssh.ConnectionFailed {
+		return FailureUnreachable // a jump host could not reach the next hop
+	}
+	return FailureCommand
+}
+
+// fail marks the check as failed with err, keeping the raw message next to
+// its classification.
+func (s *HostStatus) fail(err error) {
+	s.Online = false
+	s.Error = err.Error()
+	s.Failure = classifyError(err)
+}
+
+// retryable reports whether another attempt might succeed. Rejected
+// credentials and host keys will not change on a retry, and a missed
+// heartbeat only changes when the host pushes one.
+func (r FailureReason) retryable() bool {
+	switch r {
+	case FailureAuth, FailureHostKey, FailureMissed, "":
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want FailureReason
+	}{
+		{"dns", &net.DNSError{Err: "no such host", Name: "nope.invalid", IsNotFound: true}, FailureDNS},
+		{"refused", &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, FailureRefused},
+		{"reset", fmt.Errorf("ssh: handshake: %w", syscall.ECONNRESET), FailureRefused},
+		{"refused through a jump host", errors.New("ssh: rejected: connect failed (Connection refused)"), FailureRefused},
+		{"deadline", fmt.Errorf("probe: %w", context.DeadlineExceeded), FailureTimeout},
+		{"i/o timeout", &net.OpError{Op: "read", Err: os.ErrDeadlineExceeded}, FailureTimeout},
+		{"no route", &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.EHOSTUNREACH)}, FailureUnreachable},
+		{"network down", fmt.Errorf("dial: %w", syscall.ENETUNREACH), FailureUnreachable},
+		{"jump host cannot reach hop", &ssh.OpenChannelError{Reason: ssh.ConnectionFailed, Message: "connect failed"}, FailureUnreachable},
+		{"ssh auth", errors.New("ssh: handshake failed: ssh: unable to authenticate, attempted methods [none publickey]"), FailureAuth},
+		{"no keys", errors.New("no auth methods: set password or add a key"), FailureAuth},
+		{"bad certificate", &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}, FailureAuth},
+		{"changed host key", &HostKeyError{Host: "h", Fingerprint: "SHA256:x"}, FailureHostKey},
+		{"unknown host key in strict mode", fmt.Errorf("ssh: %w", &HostKeyError{Host: "h", Unknown: true}), FailureAuth},
+		{"anything else", errors.New("probe: exit status 1"), FailureCommand},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyError(tt.err); got != tt.want {
+				t.Errorf("classifyError(%v) = %s, want %s", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFailureRetryable(t *testing.T) {
+	for _, r := range failureReasons {
+		want := r != FailureAuth && r != FailureHostKey && r != FailureMissed
+		if got := r.retryable(); got != want {
+			t.Errorf("%s.retryable() = %v, want %v", r, got, want)
+		}
+	}
+	if FailureReason("").retryable() {
+		t.Error("a check without a failure should not be retried")
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Health is the overall condition of a host derived from its last check.
+type Health string
+
+const (
+	HealthUnknown  Health = "unknown"
+	HealthOK       Health = "ok"
+	HealthWarning  Health = "warning"
+	HealthCritical Health = "critical"
+)
+
+func (h Health) rank() int {
+	switch h {
+	case HealthOK:
+		return 1
+	case HealthWarning:
+		return 2
+	case HealthCritical:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// Threshold is a warn/critical pair. A zero value disables that level.
+type Threshold struct {
+	Warn float64 `yaml:"warn"`
+	Crit float64 `yaml:"crit"`
+}
+
+// level returns the health for value v against t.
+func (t Threshold) level(v float64) Health {
+	switch {
+	case t.Crit > 0 && v >= t.Crit:
+		return HealthCritical
+	case t.Warn > 0 && v >= t.Warn:
+		return HealthWarning
+	default:
+		return HealthOK
+	}
+}
+
+// levelBelow is level for metrics where smaller is worse, such as time
+// until a disk fills.
+func (t Threshold) levelBelow(v float64) Health {
+	switch {
+	case t.Crit > 0 && v <= t.Crit:
+		return HealthCritical
+	case t.Warn > 0 && v <= t.Warn:
+		return HealthWarning
+	default:
+		return HealthOK
+	}
+}
+
+// Thresholds configures when metrics turn a host yellow or red.
+type Thresholds struct {
+	Load    Threshold `yaml:"load"`    // 1-minute load average
+	Memory  Threshold `yaml:"memory"`  // percent used
+	Disk    Threshold `yaml:"disk"`    // percent used, any mount
+	Latency Threshold `yaml:"latency"` // check latency in milliseconds
+
+	NetRate   Threshold `yaml:"net_rate"`   // rx or tx Mbit/s on any interface
+	NetErrors Threshold `yaml:"net_errors"` // errors + drops per second on any interface
+
+	Temperature Threshold `yaml:"temperature"` // °C, hottest sensor
+	Battery     Threshold `yaml:"battery"`     // percent left while discharging; lower is worse
+}
+
+var defaultThresholds = Thresholds{
+	Memory:  Threshold{Warn: 85, Crit: 95},
+	Disk:    Threshold{Warn: 80, Crit: 90},
+	Latency: Threshold{Warn: 500, Crit: 2000},
+
+	Temperature: Threshold{Warn: 75, Crit: 85},
+	Battery:     Threshold{Warn: 20, Crit: 10},
+}
+
+// merge returns t with any non-zero fields of o layered on top.
+func (t Thresholds) merge(o Thresholds) Thresholds {
+	pick := func(a, b Threshold) Threshold {
+		if b.Warn != 0 {
+			a.Warn = b.Warn
+		}
+		if b.Crit != 0 {
+			a.Crit = b.Crit
+		}
+		return a
+	}
+	return Thresholds{
+		Load:    pick(t.Load, o.Load),
+		Memory:  pick(t.Memory, o.Memory),
+		Disk:    pick(t.Disk, o.Disk),
+		Latency: pick(t.Latency, o.Latency),
+
+		NetRate:   pick(t.NetRate, o.NetRate),
+		NetErrors: pick(t.NetErrors, o.NetErrors),
+
+		Temperature: pick(t.Temperature, o.Temperature),
+		Battery:     pick(t.Battery, o.Battery),
+	}
+}
+
+// degrade raises the status health to h (never lowers it) and records why.
+func (s *HostStatus) degrade(h Health, reason string) {
+	if h.rank() > s.Health.rank() {
+		s.Health = h
+	}
+	if h != HealthOK && reason != "" {
+		s.Reasons = append(s.Reasons, reason)
+	}
+}
+
+// evaluateHealth computes Health for a finished check from its metrics and
+// the host's thresholds.
+func evaluateHealth(s *HostStatus) {
+	s.Health = HealthUnknown
+	s.Reasons = nil
+	if s.LastCheck.IsZero() {
+		return
+	}
+	if !s.Online {
+		s.degrade(HealthCritical, "")
+		return
+	}
+	s.Health = HealthOK
+	t := s.Config.Thresholds
+	if s.Failure == FailurePartial {
+		s.degrade(HealthWarning, "partial data")
+	}
+
+	if s.Latency > 0 {
+		ms := float64(s.Latency) / float64(time.Millisecond)
+		s.degrade(t.Latency.level(ms), fmt.Sprintf("latency %.0fms", ms))
+	}
+
+	for _, c := range s.Checks {
+		reason := "check " + c.Name
+		if c.Message != "" {
+			reason += ": " + c.Message
+		}
+		s.degrade(c.Health, reason)
+	}
+
+	for _, svc := range s.Services {
+		if svc.Health == HealthWarning || svc.Health == HealthCritical {
+			s.degrade(svc.Health, fmt.Sprintf("service %s %s", svc.Name, svc.describe()))
+		}
+	}
+	for _, c := range s.Containers {
+		if c.Pinned {
+			s.degrade(c.health(), fmt.Sprintf("container %s %s", c.Name, c.describe()))
+		}
+	}
+	if s.FailedUnits > 0 {
+		s.degrade(HealthWarning, fmt.Sprintf("%d failed units", s.FailedUnits))
+	}
+
+	m := s.Metrics
+	if m == nil {
+		return
+	}
+	if m.HasLoad {
+		s.degrade(t.Load.level(m.Load1), fmt.Sprintf("load %.2f", m.Load1))
+	}
+	if m.MemTotal > 0 {
+		s.degrade(t.Memory.level(m.MemPercent()), fmt.Sprintf("mem %.0f%%", m.MemPercent()))
+	}
+	for _, ni := range m.Net {
+		if !ni.HasRates {
+			continue
+		}
+		mbit := max(ni.RxRate, ni.TxRate) * 8 / 1e6
+		s.degrade(t.NetRate.level(mbit), fmt.Sprintf("%s %.0f Mbit/s", ni.Name, mbit))
+		errs := ni.ErrorRate + ni.DropRate
+		s.degrade(t.NetErrors.level(errs), fmt.Sprintf("%s %.1f errors+drops/s", ni.Name, errs))
+	}
+	if hot, ok := m.MaxTemp(); ok {
+		s.degrade(t.Temperature.level(hot.Celsius), fmt.Sprintf("%s %.0f°C", hot.Name, hot.Celsius))
+	}
+	if m.Throttled&0xf != 0 {
+		s.degrade(HealthWarning, throttleString(m.Throttled&0xf))
+	}
+	if b := m.Battery; b != nil && !b.OnAC {
+		s.degrade(t.Battery.levelBelow(b.Percent), fmt.Sprintf("battery %.0f%%", b.Percent))
+	}
+	dc := s.Config.Disks
+	for _, d := range m.Disks {
+		s.degrade(t.Disk.level(d.Percent()), fmt.Sprintf("disk %s %.0f%%", d.Mount, d.Percent()))
+		s.degrade(t.Disk.level(d.InodePercent()), fmt.Sprintf("inodes %s %.0f%%", d.Mount, d.InodePercent()))
+		if d.FullIn > 0 {
+			fill := Threshold{Warn: dc.FillWarnHours, Crit: dc.FillCritHours}
+			s.degrade(fill.levelBelow(d.FullIn.Hours()), fmt.Sprintf("disk %s full in %s", d.Mount, formatDuration(d.FullIn)))
+		}
+	}
+}
+
+// State is the notification state of a host: "down" when unreachable,
+// otherwise its health.
+func (s HostStatus) State() string {
+	if s.HostKeyMismatch {
+		return "key_mismatch"
+	}
+	if !s.Online {
+		return "down"
+	}
+	return string(s.Health)
+}
+
+// statusLabel is the short status word shown in the table and TUI.
+func statusLabel(s HostStatus) string {
+	switch {
+	case s.LastCheck.IsZero():
+		return "----"
+	case s.HostKeyMismatch:
+		return "KEY!"
+	case s.Pending != nil:
+		return "FLAP"
+	case !s.Online:
+		return "DOWN"
+	case s.Health == HealthWarning:
+		return "WARN"
+	case s.Health == HealthCritical:
+		return "CRIT"
+	default:
+		return "UP"
+	}
+}
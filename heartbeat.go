@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// heartbeat is the last push received from a heartbeat host.
+type heartbeat struct {
+	at   time.Time
+	body []byte // agent payload JSON, empty for a bare ping
+}
+
+// heartbeatStore keeps the last push per host name. The web server records
+// pushes into it and heartbeatChecker judges them.
+type heartbeatStore struct {
+	mu    sync.Mutex
+	since time.Time // when pulse started waiting, for hosts not heard from yet
+	beats map[string]heartbeat
+	used  map[string]usedBeat // host name -> last push turned into a payload
+}
+
+// usedBeat is a push's payload after sampling, never modified.
+type usedBeat struct {
+	at time.Time
+	p  agentPayload
+}
+
+func newHeartbeatStore() *heartbeatStore {
+	return &heartbeatStore{
+		since: time.Now(),
+		beats: make(map[string]heartbeat),
+		used:  make(map[string]usedBeat),
+	}
+}
+
+func (hs *heartbeatStore) record(name string, body []byte) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	hs.beats[name] = heartbeat{at: time.Now(), body: body}
+}
+
+// last returns the host's latest push. Hosts not heard from yet count from
+// when pulse started, so a restart does not mark them all down at once.
+func (hs *heartbeatStore) last(name string) (heartbeat, bool) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	b, ok := hs.beats[name]
+	if !ok {
+		b.at = hs.since
+	}
+	return b, ok
+}
+
+// payload returns what beat carried for hc. Each push is sampled once into
+// disks, at the time it arrived: disk forecasts and network rates only see
+// new pushes, and checks in between report the last one as it was.
+func (hs *heartbeatStore) payload(hc HostConfig, beat heartbeat, disks *diskTrends) (agentPayload, bool) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	prev, ok := hs.used[hc.Name]
+	if ok && !beat.at.After(prev.at) {
+		return prev.p, true
+	}
+	var p agentPayload
+	if err := json.Unmarshal(beat.body, &p); err != nil {
+		return agentPayload{}, false
+	}
+	p.sample(disks, hc, beat.at)
+	if ok && p.Metrics != nil && prev.p.Metrics != nil {
+		netRates(p.Metrics.Net, prev.p.Metrics.Net, beat.at.Sub(prev.at))
+	}
+	hs.used[hc.Name] = usedBeat{beat.at, p}
+	return p, true
+}
+
+// dropHeartbeats removes heartbeat hosts, warning about each: only the web
+// server receives pushes, so anywhere else they would just go down.
+func dropHeartbeats(hosts []HostConfig) []HostConfig {
+	var out []HostConfig
+	for _, hc := range hosts {
+		if hc.Type == "heartbeat" {
+			fmt.Fprintf(os.Stderr, "Warning: skipping heartbeat host %s: pushes are only received with --web\n", hc.Label)
+			continue
+		}
+		out = append(out, hc)
+	}
+	return out
+}
+
+// heartbeatChecker judges a host that pushes to POST /api/heartbeat/{name}
+// instead of being polled: it is up while its last push is within the grace
+// period, with whatever metrics that push carried.
+type heartbeatChecker struct{}
+
+func (heartbeatChecker) Check(_ context.Context, mon *monitor, hc HostConfig, status *HostStatus) {
+	beat, ok := mon.heartbeats.last(hc.Name)
+	ago := time.Since(beat.at).Round(time.Second)
+	switch {
+	case ago > hc.grace() && !ok:
+		status.Error = fmt.Sprintf("heartbeat: none received in %s", ago)
+		status.Failure = FailureMissed
+		return
+	case ago > hc.grace():
+		status.Error = fmt.Sprintf("heartbeat: last one %s ago", ago)
+		status.Failure = FailureMissed
+		return
+	}
+	if len(beat.body) > 0 {
+		if p, ok := mon.heartbeats.payload(hc, beat, mon.disks); ok {
+			p.copyTo(status)
+		}
+	}
+	status.Online = true
+	if ok {
+		status.Detail = fmt.Sprintf("heartbeat %s ago", ago)
+	} else {
+		status.Detail = "waiting for first heartbeat"
+	}
+}
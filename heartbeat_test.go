@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestHeartbeatSamplesEachPushOnce(t *testing.T) {
+	cfg, err := parseConfig([]byte("hosts:\n  - {name: hb-test, type: heartbeat, token: x, grace: 600}\n"))
+	if err != nil {
+		t.Fatalf("parseConfig: %v", err)
+	}
+	hc := cfg.Hosts[0]
+	mon := newMonitor()
+	push := func(at time.Time, rx, used uint64) {
+		body := fmt.Sprintf(`{"online": true, "metrics": {"interfaces": [{"name": "eth0", "rx_bytes": %d, "tx_bytes": 1}],
+			"disks": [{"mount": "/", "used_bytes": %d, "total_bytes": 1000000, "free_bytes": %d}]}}`, rx, used, 1000000-used)
+		mon.heartbeats.mu.Lock()
+		mon.heartbeats.beats[hc.Name] = heartbeat{at: at, body: []byte(body)}
+		mon.heartbeats.mu.Unlock()
+	}
+	check := func() HostStatus {
+		t.Helper()
+		s := mon.checkHost(t.Context(), hc)
+		if !s.Online || s.Metrics == nil || len(s.Metrics.Net) != 1 {
+			t.Fatalf("status = %+v, want online with one interface", s)
+		}
+		return s
+	}
+	sampledAt := func() []time.Time {
+		mon.disks.mu.Lock()
+		defer mon.disks.mu.Unlock()
+		var at []time.Time
+		for _, s := range mon.disks.samples[hc.Name+"\x00/"] {
+			at = append(at, s.at)
+		}
+		return at
+	}
+
+	first := time.Now().Add(-2 * time.Minute)
+	push(first, 1000, 1000)
+	check()
+	if s := check(); s.Metrics.Net[0].HasRates {
+		t.Errorf("rates from a single push: %+v", s.Metrics.Net[0])
+	}
+	if at := sampledAt(); len(at) != 1 || !at[0].Equal(first) {
+		t.Errorf("disk sampled at %v, want once at the push %v", at, first)
+	}
+
+	push(first.Add(2*time.Minute), 13000, 2000)
+	for range 2 {
+		ni := check().Metrics.Net[0]
+		if !ni.HasRates || ni.RxRate != 100 {
+			t.Errorf("rx rate = %v (has %v), want 100/s between pushes", ni.RxRate, ni.HasRates)
+		}
+	}
+	if at := sampledAt(); len(at) != 2 {
+		t.Errorf("disk sampled at %v, want once per push", at)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Host key checking modes for HostConfig.HostKeyCheck.
+const (
+	hostKeyTOFU   = "tofu"   // accept and pin unseen keys, reject changed ones
+	hostKeyStrict = "strict" // only accept keys already in a known_hosts file
+	hostKeyOff    = "off"    // accept anything (insecure)
+)
+
+// HostKeyError reports a host key that could not be verified.
+type HostKeyError struct {
+	Host        string
+	Fingerprint string // SHA256 fingerprint presented by the server
+	Unknown     bool   // true if no key is on file (strict mode only)
+}
+
+func (e *HostKeyError) Error() string {
+	if e.Unknown {
+		return fmt.Sprintf("host key for %s is not in known_hosts (%s)", e.Host, e.Fingerprint)
+	}
+	return fmt.Sprintf("HOST KEY MISMATCH for %s: server presented %s", e.Host, e.Fingerprint)
+}
+
+// knownHostsMu serialises TOFU writes to the pulse-managed known_hosts file.
+var knownHostsMu sync.Mutex
+
+func defaultKnownHostsPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "pulse", "known_hosts")
+}
+
+// hostKeyCallback verifies server keys against ~/.ssh/known_hosts (hashed
+// entries included) and the pulse-managed known_hosts file.
+func hostKeyCallback(hc HostConfig) ssh.HostKeyCallback {
+	if hc.HostKeyCheck == hostKeyOff {
+		return ssh.InsecureIgnoreHostKey()
+	}
+	pulseFile := expandHome(hc.KnownHostsFile)
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		files := knownHostsFiles(hc)
+
+		knownHostsMu.Lock()
+		defer knownHostsMu.Unlock()
+
+		err := errUnknownHost
+		if len(files) > 0 {
+			cb, cerr := knownhosts.New(files...)
+			if cerr != nil {
+				return fmt.Errorf("known_hosts: %w", cerr)
+			}
+			err = cb(hostname, remote, key)
+		}
+		if err == nil {
+			return nil
+		}
+
+		var keyErr *knownhosts.KeyError
+		unknown := errors.Is(err, errUnknownHost) || (errors.As(err, &keyErr) && len(keyErr.Want) == 0)
+		if !unknown && keyErr == nil {
+			return err // revoked key or similar
+		}
+		fp := ssh.FingerprintSHA256(key)
+		if !unknown {
+			return &HostKeyError{Host: hostname, Fingerprint: fp}
+		}
+		if hc.HostKeyCheck == hostKeyStrict {
+			return &HostKeyError{Host: hostname, Fingerprint: fp, Unknown: true}
+		}
+		return pinHostKey(pulseFile, hostname, key)
+	}
+}
+
+var errUnknownHost = errors.New("unknown host")
+
+// knownHostsFiles returns the known_hosts files that exist for hc.
+func knownHostsFiles(hc HostConfig) []string {
+	home, _ := os.UserHomeDir()
+	var files []string
+	for _, f := range []string{filepath.Join(home, ".ssh", "known_hosts"), expandHome(hc.KnownHostsFile)} {
+		if _, err := os.Stat(f); err == nil {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// hostKeyAlgorithms lists the algorithms of the keys on file for hostname,
+// for ssh.ClientConfig.HostKeyAlgorithms. Otherwise the server may offer,
+// say, its ed25519 key when only its RSA key is known, which knownhosts
+// reports as a mismatch. It returns nil, allowing any, when no key is on
+// file or checking is off.
+func hostKeyAlgorithms(hc HostConfig, hostname string, remote net.Addr) []string {
+	if hc.HostKeyCheck == hostKeyOff {
+		return nil
+	}
+	files := knownHostsFiles(hc)
+	if len(files) == 0 {
+		return nil
+	}
+	knownHostsMu.Lock()
+	cb, err := knownhosts.New(files...)
+	knownHostsMu.Unlock()
+	if err != nil {
+		return nil
+	}
+	// No key matches a lookupKey, so the error lists every key on file.
+	var keyErr *knownhosts.KeyError
+	if !errors.As(cb(hostname, remote, lookupKey{}), &keyErr) {
+		return nil
+	}
+	var algos []string
+	for _, k := range keyErr.Want {
+		names := []string{k.Key.Type()}
+		if names[0] == ssh.KeyAlgoRSA {
+			names = []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+		}
+		for _, n := range names {
+			if !slices.Contains(algos, n) {
+				algos = append(algos, n)
+			}
+		}
+	}
+	return algos
+}
+
+// lookupKey is a public key no known_hosts entry matches.
+type lookupKey struct{}
+
+func (lookupKey) Type() string                        { return "pulse-lookup" }
+func (lookupKey) Marshal() []byte                     { return []byte("pulse-lookup") }
+func (lookupKey) Verify([]byte, *ssh.Signature) error { return errors.New("lookup key") }
+
+// pinHostKey appends key for hostname to the pulse-managed known_hosts file.
+func pinHostKey(path, hostname string, key ssh.PublicKey) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("pin host key: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("pin host key: %w", err)
+	}
+	defer f.Close()
+	line := knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)
+	if _, err := fmt.Fprintln(f, line); err != nil {
+		return fmt.Errorf("pin host key: %w", err)
+	}
+	return nil
+}
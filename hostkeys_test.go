@@ -0,0 +1,153 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func testSigners(t *testing.T) (ed, rs, ec ssh.Signer) {
+	t.Helper()
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	for i, k := range []any{edKey, rsaKey, ecKey} {
+		s, err := ssh.NewSignerFromKey(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch i {
+		case 0:
+			ed = s
+		case 1:
+			rs = s
+		default:
+			ec = s
+		}
+	}
+	return ed, rs, ec
+}
+
+// testSSHServer accepts any client on a local port, presenting keys.
+func testSSHServer(t *testing.T, keys ...ssh.Signer) string {
+	t.Helper()
+	cfg := &ssh.ServerConfig{NoClientAuth: true}
+	for _, k := range keys {
+		cfg.AddHostKey(k)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(conn, cfg)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for ch := range chans {
+					ch.Reject(ssh.Prohibited, "test server")
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// testHostConfig returns a strict ssh host for addr whose pulse known_hosts
+// file holds known, with no ~/.ssh files in the way.
+func testHostConfig(t *testing.T, addr string, known ...ssh.PublicKey) HostConfig {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("SSH_AUTH_SOCK", "")
+	kh := filepath.Join(home, "known_hosts")
+	var lines []string
+	for _, k := range known {
+		lines = append(lines, knownhosts.Line([]string{knownhosts.Normalize(addr)}, k))
+	}
+	if err := os.WriteFile(kh, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	host, port, _ := net.SplitHostPort(addr)
+	cfg, err := parseConfig([]byte(fmt.Sprintf(
+		"hosts:\n  - {host: %s, port: %s, user: u, password: p, host_key_check: strict, known_hosts_file: %s}\n", host, port, kh)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg.Hosts[0]
+}
+
+func TestHostKeyAlgorithms(t *testing.T) {
+	ed, rs, ec := testSigners(t)
+	addr := "10.0.0.1:22"
+	remote := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 22}
+	tests := []struct {
+		name  string
+		known []ssh.PublicKey
+		want  []string
+	}{
+		{"nothing on file", nil, nil},
+		{"rsa only", []ssh.PublicKey{rs.PublicKey()}, []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}},
+		{"ecdsa and ed25519", []ssh.PublicKey{ec.PublicKey(), ed.PublicKey()}, []string{ssh.KeyAlgoECDSA256, ssh.KeyAlgoED25519}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hc := testHostConfig(t, addr, tt.known...)
+			got := hostKeyAlgorithms(hc, addr, remote)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("hostKeyAlgorithms = %v, want %v", got, tt.want)
+			}
+			hc.HostKeyCheck = hostKeyOff
+			if got := hostKeyAlgorithms(hc, addr, remote); got != nil {
+				t.Errorf("with checking off = %v, want nil", got)
+			}
+		})
+	}
+}
+
+func TestSSHDialNegotiatesKnownKeyType(t *testing.T) {
+	ed, rs, ec := testSigners(t)
+	addr := testSSHServer(t, ed, rs, ec)
+	for _, known := range []ssh.Signer{rs, ec, ed} {
+		t.Run(known.PublicKey().Type(), func(t *testing.T) {
+			hc := testHostConfig(t, addr, known.PublicKey())
+			client, err := sshDial(t.Context(), hc, nil)
+			if err != nil {
+				t.Fatalf("sshDial with only the %s key on file: %v", known.PublicKey().Type(), err)
+			}
+			client.Close()
+		})
+	}
+
+	t.Run("changed key", func(t *testing.T) {
+		other, _, _ := testSigners(t)
+		hc := testHostConfig(t, addr, other.PublicKey())
+		_, err := sshDial(t.Context(), hc, nil)
+		if err == nil || !strings.Contains(err.Error(), "HOST KEY MISMATCH") {
+			t.Errorf("sshDial with a different key on file: %v, want a mismatch", err)
+		}
+	})
+}
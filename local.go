@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// localChecker checks the machine pulse runs on without logging in to it.
+// On Linux the core metrics, network counters and sensors are read straight
+// from /proc, /sys and statfs (see readLocal); anything that needs a command
+// (services, containers, processes, custom checks, Pi throttling), and every
+// metric elsewhere, comes from the usual probe script run by a local sh.
+// Either way the result is the same HostStatus an ssh check produces.
+type localChecker struct{}
+
+func (localChecker) Check(ctx context.Context, mon *monitor, hc HostConfig, status *HostStatus) {
+	native := probeResult{}
+	dctx, cancel := context.WithTimeout(ctx, hc.timeout())
+	disks, stuck, ok := readLocal(dctx, native)
+	cancel()
+	commands := commandProbe(hc, mon.updates.due(hc))
+	parts := []string{probeCore, netProbe, sensorProbe, commands}
+	if ok {
+		parts = []string{throttleProbe, commands}
+	}
+
+	pctx, cancel := context.WithTimeout(ctx, max(probeTimeout, hc.timeout()))
+	defer cancel()
+	out, err := runLocalScript(pctx, script(parts...))
+	probe, perr := parseProbe(out)
+	if perr != nil {
+		if err != nil {
+			perr = err
+		}
+		status.fail(perr)
+		return
+	}
+	status.Online = true
+	maps.Copy(probe, native)
+	m := probe.metrics()
+	if ok {
+		m.Disks = disks
+	}
+	mon.applyProbe(status, hc, probe, m)
+	if len(stuck) > 0 && status.Failure == "" {
+		status.Error = "statfs: no answer from " + strings.Join(stuck, ", ")
+		status.Failure = FailurePartial
+	}
+}
+
+// runLocalScript feeds script to `sh -s` on this machine, like runScript
+// does over SSH.
+func runLocalScript(ctx context.Context, script string) (string, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-s")
+	cmd.Stdin = strings.NewReader(script)
+	cmd.WaitDelay = time.Second // don't wait on children that keep stdout open
+	out, err := cmd.Output()
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	return string(out), err
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// readLocal fills p with the keys probeCore, netProbe and sensorProbe would
+// print, read directly from /proc and /sys, and returns the mounted
+// filesystems from statfs along with any mounts that did not answer before
+// ctx ended. It reports false when /proc is not mounted.
+func readLocal(ctx context.Context, p probeResult) ([]DiskUsage, []string, bool) {
+	loadavg, ok := readTrimmed("/proc/loadavg")
+	if !ok {
+		return nil, nil, false
+	}
+	p["os"] = []string{"Linux"}
+	if kernel, ok := readTrimmed("/proc/sys/kernel/osrelease"); ok {
+		p["kernel"] = []string{kernel}
+	}
+	if f := strings.Fields(loadavg); len(f) >= 3 {
+		p["load"] = []string{strings.Join(f[:3], " ")}
+	}
+	if meminfo, ok := readTrimmed("/proc/meminfo"); ok {
+		var total, avail uint64
+		for _, line := range strings.Split(meminfo, "\n") {
+			f := strings.Fields(line)
+			if len(f) < 2 {
+				continue
+			}
+			switch f[0] {
+			case "MemTotal:":
+				total, _ = strconv.ParseUint(f[1], 10, 64)
+			case "MemAvailable:":
+				avail, _ = strconv.ParseUint(f[1], 10, 64)
+			}
+		}
+		if total > 0 {
+			p["mem_total"] = []string{strconv.FormatUint(total*1024, 10)}
+			p["mem_used"] = []string{strconv.FormatUint((total-avail)*1024, 10)}
+		}
+	}
+	if uptime, ok := readTrimmed("/proc/uptime"); ok {
+		secs, _, _ := strings.Cut(uptime, ".")
+		p["uptime"] = []string{secs}
+	}
+	if netdev, ok := readTrimmed("/proc/net/dev"); ok {
+		lines := strings.Split(netdev, "\n")
+		for _, line := range lines[min(2, len(lines)):] {
+			p["netdev"] = append(p["netdev"], strings.TrimSpace(line))
+		}
+	}
+
+	zones, _ := filepath.Glob("/sys/class/thermal/thermal_zone*")
+	for _, z := range zones {
+		name, _ := readTrimmed(z + "/type")
+		if temp, ok := readTrimmed(z + "/temp"); ok {
+			p["zone"] = append(p["zone"], name+" "+temp)
+		}
+	}
+	inputs, _ := filepath.Glob("/sys/class/hwmon/hwmon*/temp*_input")
+	for _, t := range inputs {
+		temp, ok := readTrimmed(t)
+		if !ok {
+			continue
+		}
+		label, ok := readTrimmed(strings.TrimSuffix(t, "_input") + "_label")
+		if !ok {
+			label = strings.TrimSuffix(filepath.Base(t), "_input")
+		}
+		chip, _ := readTrimmed(filepath.Dir(t) + "/name")
+		p["hwmon"] = append(p["hwmon"], chip+"/"+label+" "+temp)
+	}
+	supplies, _ := filepath.Glob("/sys/class/power_supply/*")
+	for _, ps := range supplies {
+		switch kind, _ := readTrimmed(ps + "/type"); kind {
+		case "Battery":
+			capacity, _ := readTrimmed(ps + "/capacity")
+			status, _ := readTrimmed(ps + "/status")
+			p["battery"] = append(p["battery"], capacity+" "+status)
+		case "Mains", "USB":
+			if online, _ := readTrimmed(ps + "/online"); online == "1" {
+				p["ac"] = []string{"1"}
+			}
+		}
+	}
+	disks, stuck := localDisks(ctx)
+	return disks, stuck, true
+}
+
+// localDisks runs statfs on every real filesystem in /proc/self/mounts,
+// skipping repeated mounts of the same device like parseDF. The calls run
+// side by side; mounts still busy when ctx ends (a hung NFS server, say)
+// are returned as stuck instead.
+func localDisks(ctx context.Context) (disks []DiskUsage, stuck []string) {
+	mounts, ok := readTrimmed("/proc/self/mounts")
+	if !ok {
+		return nil, nil
+	}
+	type call struct {
+		dev, mount string
+		done       <-chan statfsResult
+	}
+	var calls []call
+	for _, line := range strings.Split(mounts, "\n") {
+		f := strings.Fields(line)
+		if len(f) < 2 {
+			continue
+		}
+		dev, mount := unescapeMount(f[0]), unescapeMount(f[1])
+		if realFilesystem(dev, mount) {
+			calls = append(calls, call{dev, mount, startStatfs(mount)})
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, c := range calls {
+		if seen[c.dev] {
+			continue
+		}
+		var r statfsResult
+		select {
+		case r = <-c.done:
+		case <-ctx.Done():
+			seen[c.dev] = true
+			stuck = append(stuck, c.mount)
+			continue
+		}
+		st := r.st
+		if r.err != nil || st.Blocks == 0 {
+			continue
+		}
+		seen[c.dev] = true
+		bs := uint64(st.Bsize)
+		disks = append(disks, DiskUsage{
+			Mount:       c.mount,
+			Device:      c.dev,
+			Used:        (st.Blocks - st.Bfree) * bs,
+			Total:       st.Blocks * bs,
+			Free:        st.Bavail * bs,
+			InodesUsed:  st.Files - st.Ffree,
+			InodesTotal: st.Files,
+		})
+	}
+	return disks, stuck
+}
+
+type statfsResult struct {
+	st  syscall.Statfs_t
+	err error
+}
+
+var (
+	statfsMu   sync.Mutex
+	statfsBusy = make(map[string]bool) // mounts whose statfs has not returned
+)
+
+// startStatfs runs statfs on mount in the background. A hung network mount
+// blocks it indefinitely, so while a call for mount is outstanding no other
+// is started and the returned channel is nil.
+func startStatfs(mount string) <-chan statfsResult {
+	statfsMu.Lock()
+	defer statfsMu.Unlock()
+	if statfsBusy[mount] {
+		return nil
+	}
+	statfsBusy[mount] = true
+	done := make(chan statfsResult, 1)
+	go func() {
+		var r statfsResult
+		r.err = syscall.Statfs(mount, &r.st)
+		statfsMu.Lock()
+		delete(statfsBusy, mount)
+		statfsMu.Unlock()
+		done <- r
+	}()
+	return done
+}
+
+// unescapeMount undoes the octal escapes (\040 for a space) that
+// /proc/mounts uses in device and mount point names.
+func unescapeMount(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// readTrimmed returns the contents of a small /proc or /sys file.
+func readTrimmed(path string) (string, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(string(data)), true
+}
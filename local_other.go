@@ -0,0 +1,11 @@
+//go:build !linux
+
+package main
+
+import "context"
+
+// readLocal only reads metrics natively on Linux; elsewhere localChecker
+// runs the whole probe script through sh.
+func readLocal(context.Context, probeResult) ([]DiskUsage, []string, bool) {
+	return nil, nil, false
+}
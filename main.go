@@ -1,11 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -14,10 +19,21 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "agent":
+			runAgent(os.Args[2:])
+			return
+		case "exec":
+			runExec(os.Args[2:])
+			return
+		}
+	}
+
 	configPath := flag.String("config", defaultConfigPath(), "config file path")
 	once := flag.Bool("once", false, "check once and exit (no TUI)")
 	watch := flag.Bool("watch", false, "check repeatedly without TUI")
-	jsonOut := flag.Bool("json", false, "output as JSON (with --once or --watch)")
+	jsonOut := flag.Bool("json", false, "output as JSON (with --once, or one line per result with --watch)")
 	web := flag.Bool("web", false, "start web dashboard")
 	webPort := flag.Int("port", 9100, "web dashboard port")
 	initFlag := flag.Bool("init", false, "create sample config file")
@@ -42,38 +58,63 @@ func main() {
 		}
 		os.Exit(1)
 	}
+	if !*web {
+		cfg.Hosts = dropHeartbeats(cfg.Hosts)
+	}
 
 	if len(cfg.Hosts) == 0 {
 		fmt.Fprintln(os.Stderr, "No hosts configured. Edit your config file.")
 		os.Exit(1)
 	}
 
+	// Cancel in-flight checks on Ctrl-C / SIGTERM outside the TUI, which
+	// handles its own keys.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	mon := newMonitor()
+
 	if *web {
-		ws := NewWebServer(cfg, *webPort)
-		if err := ws.Run(); err != nil {
+		ws := NewWebServer(cfg, *webPort, mon)
+		if err := ws.Run(ctx); err != nil {
 			fmt.Fprintf(os.Stderr, "Web server error: %v\n", err)
 			os.Exit(1)
 		}
 		return
 	}
 
-	if *once || *watch {
+	if *once {
+		results := mon.checkAllHosts(ctx, cfg)
+		if *jsonOut {
+			printJSON(results)
+		} else {
+			printTable(results)
+		}
+		mon.close()
+		return
+	}
+
+	if *watch {
+		// Print each host's result as it arrives.
 		tracker := NewStateTracker(cfg.Notify)
+		results := make(chan HostStatus, len(cfg.Hosts))
+		go mon.watchHosts(ctx, cfg, results)
 		for {
-			results := checkAllHosts(cfg)
-			transitions := tracker.Update(results)
-			if *jsonOut {
-				printJSON(results)
-			} else {
-				printTable(results)
-			}
-			for _, t := range transitions {
-				fmt.Fprintf(os.Stderr, "⚠ %s\n", t)
-			}
-			if *once {
+			select {
+			case r := <-results:
+				batch := []HostStatus{r}
+				transitions := tracker.Update(batch)
+				if *jsonOut {
+					json.NewEncoder(os.Stdout).Encode(newJSONResult(batch[0]))
+				} else {
+					printTable(batch)
+				}
+				for _, t := range transitions {
+					fmt.Fprintf(os.Stderr, "⚠ %s\n", t)
+				}
+			case <-ctx.Done():
+				mon.close()
 				return
 			}
-			time.Sleep(time.Duration(cfg.Interval) * time.Second)
 		}
 	}
 
@@ -93,67 +134,193 @@ func main() {
 		store = &dispatch.Store{}
 	}
 	jm := newJiraModel(jiraCfg, cfg.Hosts, store)
-	m := initialModel(cfg, false, jm)
+	m := initialModel(cfg, false, jm, mon)
 	p := tea.NewProgram(m, tea.WithAltScreen())
-	if _, err := p.Run(); err != nil {
+	_, err = p.Run()
+	mon.close()
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 }
 
-func checkAllHosts(cfg *Config) []HostStatus {
-	results := make([]HostStatus, len(cfg.Hosts))
-	ch := make(chan struct{ idx int; status HostStatus }, len(cfg.Hosts))
-	for i, h := range cfg.Hosts {
-		go func(idx int, hc HostConfig) {
-			ch <- struct{ idx int; status HostStatus }{idx, checkHost(hc)}
-		}(i, h)
+// runAgent implements `pulse agent`: serve this machine's status to a
+// polling pulse over HTTP(S).
+func runAgent(args []string) {
+	fs := flag.NewFlagSet("pulse agent", flag.ExitOnError)
+	listen := fs.String("listen", fmt.Sprintf(":%d", defaultAgentPort), "address to listen on")
+	configPath := fs.String("config", "", "pulse config; its first local host sets services, checks, etc.")
+	token := fs.String("token", "", "shared secret (default $"+agentTokenEnv+")")
+	certFile := fs.String("tls-cert", "", "serve HTTPS with this certificate")
+	keyFile := fs.String("tls-key", "", "key for --tls-cert")
+	fs.Parse(args)
+
+	if *token == "" {
+		*token = os.Getenv(agentTokenEnv)
+	}
+	if *token == "" {
+		fmt.Fprintf(os.Stderr, "pulse agent needs --token or %s\n", agentTokenEnv)
+		os.Exit(1)
+	}
+	hc, err := agentHost(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := NewAgent(hc, *token, *listen).Run(ctx, *certFile, *keyFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Agent error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// runExec implements `pulse exec`: run one command on many hosts at once,
+// streaming output prefixed by host label, then print a summary. Exits 1
+// if any host failed or returned non-zero.
+func runExec(args []string) {
+	fs := flag.NewFlagSet("pulse exec", flag.ExitOnError)
+	configPath := fs.String("config", defaultConfigPath(), "config file path")
+	only := fs.String("hosts", "", "comma-separated host names or labels, globs allowed (default: all ssh and local hosts)")
+	timeout := fs.Float64("timeout", 60, "seconds the command may run on each host")
+	jsonOut := fs.Bool("json", false, "print results as JSON instead of streaming output")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: pulse exec [flags] command...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	cmd := strings.Join(fs.Args(), " ")
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	var globs []string
+	if *only != "" {
+		globs = strings.Split(*only, ",")
+	}
+	hosts := execHosts(cfg, globs)
+	if len(hosts) == 0 {
+		fmt.Fprintln(os.Stderr, "No matching ssh or local hosts.")
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	width := 0
+	for _, hc := range hosts {
+		width = max(width, len(hc.Label))
+	}
+	var mu sync.Mutex
+	out := make([]*lineWriter, len(hosts))
+	for i, hc := range hosts {
+		if *jsonOut {
+			out[i] = &lineWriter{mu: new(sync.Mutex), dst: new(bytes.Buffer)}
+		} else {
+			out[i] = &lineWriter{mu: &mu, dst: os.Stdout, prefix: fmt.Sprintf("%-*s | ", width, hc.Label)}
+		}
+	}
+	results := execAll(ctx, cfg, hosts, cmd, time.Duration(*timeout*float64(time.Second)), out)
+
+	failed := false
+	for i := range results {
+		if *jsonOut {
+			results[i].Output = out[i].dst.(*bytes.Buffer).String()
+		}
+		failed = failed || results[i].ExitCode != 0
+	}
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(results)
+	} else {
+		fmt.Println()
+		printExecSummary(os.Stdout, results)
 	}
-	for range cfg.Hosts {
-		r := <-ch
-		results[r.idx] = r.status
+	if failed {
+		os.Exit(1)
 	}
-	return results
 }
 
 func printTable(results []HostStatus) {
 	for _, r := range results {
-		status := "DOWN"
+		status := statusLabel(r)
 		detail := r.Error
+		if r.Failure != "" {
+			detail = string(r.Failure) + ": " + detail
+		}
 		if r.Online {
-			status = "UP"
 			parts := []string{}
-			if r.CPU != "" {
-				parts = append(parts, "load:"+r.CPU)
+			if s := r.Metrics.LoadString(); s != "" {
+				parts = append(parts, "load:"+s)
+			}
+			if s := r.Metrics.MemoryString(); s != "" {
+				parts = append(parts, "mem:"+s)
+			}
+			if s := r.Metrics.DiskString(); s != "" {
+				parts = append(parts, "disk:"+s)
+			}
+			if s := r.Metrics.UptimeString(); s != "" {
+				parts = append(parts, "up:"+s)
 			}
-			if r.Memory != "" {
-				parts = append(parts, "mem:"+r.Memory)
+			if s := r.Updates.String(); s != "" {
+				parts = append(parts, s)
 			}
-			if r.Disk != "" {
-				parts = append(parts, "disk:"+r.Disk)
+			if r.Detail != "" {
+				parts = append(parts, r.Detail)
 			}
-			if r.Uptime != "" {
-				parts = append(parts, "up:"+r.Uptime)
+			if r.Latency > 0 {
+				parts = append(parts, "rtt:"+formatLatency(r.Latency))
 			}
 			detail = strings.Join(parts, " | ")
+			if len(r.Reasons) > 0 {
+				detail += "  [" + strings.Join(r.Reasons, ", ") + "]"
+			}
 		}
 		fmt.Printf("%-5s %-20s %s\n", status, r.Config.Label, detail)
 	}
 }
 
 type jsonResult struct {
-	Name          string  `json:"name"`
-	Host          string  `json:"host"`
-	Online        bool    `json:"online"`
-	CPU           string  `json:"cpu,omitempty"`
-	Memory        string  `json:"memory,omitempty"`
-	Disk          string  `json:"disk,omitempty"`
-	Uptime        string  `json:"uptime,omitempty"`
-	Error         string  `json:"error,omitempty"`
-	CheckAt       string  `json:"checked_at"`
-	Sparkline     string  `json:"sparkline,omitempty"`
-	UptimePercent float64 `json:"uptime_percent,omitempty"`
-	CheckCount    int     `json:"check_count,omitempty"`
+	ID            string            `json:"id"`
+	Name          string            `json:"name"`
+	Type          string            `json:"type"`
+	Host          string            `json:"host"`
+	Online        bool              `json:"online"`
+	Health        Health            `json:"health"`
+	Reasons       []string          `json:"reasons,omitempty"`
+	KeyMismatch   bool              `json:"host_key_mismatch,omitempty"`
+	FailedHop     string            `json:"failed_hop,omitempty"`
+	Checks        []CheckResult     `json:"checks,omitempty"`
+	Services      []ServiceStatus   `json:"services,omitempty"`
+	FailedUnits   []string          `json:"failed_units,omitempty"`
+	Containers    []ContainerStatus `json:"containers,omitempty"`
+	TopCPU        []Process         `json:"top_cpu,omitempty"`
+	TopRSS        []Process         `json:"top_rss,omitempty"`
+	Updates       *Updates          `json:"updates,omitempty"`
+	CPU           string            `json:"cpu,omitempty"`
+	Memory        string            `json:"memory,omitempty"`
+	Disk          string            `json:"disk,omitempty"`
+	Uptime        string            `json:"uptime,omitempty"`
+	Detail        string            `json:"detail,omitempty"`
+	Metrics       *Metrics          `json:"metrics,omitempty"`
+	LatencyMS     float64           `json:"latency_ms,omitempty"`
+	Error         string            `json:"error,omitempty"`
+	Failure       FailureReason     `json:"failure,omitempty"`
+	Attempts      int               `json:"attempts,omitempty"`
+	Pending       *PendingState     `json:"pending,omitempty"`
+	IntervalSec   float64           `json:"interval_seconds,omitempty"`
+	CheckAt       string            `json:"checked_at"`
+	Sparkline     string            `json:"sparkline,omitempty"`
+	UptimePercent float64           `json:"uptime_percent,omitempty"`
+	CheckCount    int               `json:"check_count,omitempty"`
 }
 
 func envOrDefault(key, fallback string) string {
@@ -163,20 +330,49 @@ func envOrDefault(key, fallback string) string {
 	return fallback
 }
 
+func newJSONResult(r HostStatus) jsonResult {
+	return jsonResult{
+		ID:          r.Config.Name,
+		Name:        r.Config.Label,
+		Type:        r.Config.Type,
+		Host:        r.Config.Host,
+		Online:      r.Online,
+		Health:      r.Health,
+		Reasons:     r.Reasons,
+		KeyMismatch: r.HostKeyMismatch,
+		FailedHop:   r.FailedHop,
+		Checks:      r.Checks,
+		Services:    r.Services,
+		FailedUnits: r.FailedUnitNames,
+		Containers:  r.Containers,
+		TopCPU:      r.TopCPU,
+		TopRSS:      r.TopRSS,
+		Updates:     r.Updates,
+		CPU:         r.Metrics.LoadString(),
+		Memory:      r.Metrics.MemoryString(),
+		Disk:        r.Metrics.DiskString(),
+		Uptime:      r.Metrics.UptimeString(),
+		Detail:      r.Detail,
+		Metrics:     r.Metrics,
+		LatencyMS:   float64(r.Latency.Microseconds()) / 1000,
+		Error:       r.Error,
+		Failure:     r.Failure,
+		Attempts:    r.Attempts,
+		Pending:     r.Pending,
+		IntervalSec: r.Interval.Seconds(),
+		CheckAt:     r.LastCheck.Format(time.RFC3339),
+	}
+}
+
+// formatLatency renders a check round-trip time in milliseconds.
+func formatLatency(d time.Duration) string {
+	return fmt.Sprintf("%.0fms", float64(d.Microseconds())/1000)
+}
+
 func printJSON(results []HostStatus) {
 	out := make([]jsonResult, len(results))
 	for i, r := range results {
-		out[i] = jsonResult{
-			Name:    r.Config.Label,
-			Host:    r.Config.Host,
-			Online:  r.Online,
-			CPU:     r.CPU,
-			Memory:  r.Memory,
-			Disk:    r.Disk,
-			Uptime:  r.Uptime,
-			Error:   r.Error,
-			CheckAt: r.LastCheck.Format(time.RFC3339),
-		}
+		out[i] = newJSONResult(r)
 	}
 	enc := json.NewEncoder(os.Stdout)
 	enc.SetIndent("", "  ")
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Metrics holds the numeric readings gathered from a host. Formatting for
+// display lives in the String helpers below so every view renders alike.
+type Metrics struct {
+	Load1    float64        `json:"-"` // load1, load5, load15 in JSON, only with HasLoad
+	Load5    float64        `json:"-"`
+	Load15   float64        `json:"-"`
+	HasLoad  bool           `json:"-"`
+	MemUsed  uint64         `json:"mem_used_bytes,omitempty"`
+	MemTotal uint64         `json:"mem_total_bytes,omitempty"`
+	Disks    []DiskUsage    `json:"disks,omitempty"`
+	Net      []NetInterface `json:"interfaces,omitempty"`
+	Uptime   time.Duration  `json:"-"`
+
+	Temps     []Temperature `json:"temperatures,omitempty"`
+	Throttled uint32        `json:"throttled,omitempty"` // vcgencmd get_throttled bits
+	Battery   *Battery      `json:"battery,omitempty"`
+}
+
+// DiskUsage is the space and inode usage of a single mount.
+type DiskUsage struct {
+	Mount       string        `json:"mount"`
+	Device      string        `json:"device,omitempty"`
+	Used        uint64        `json:"used_bytes"`
+	Total       uint64        `json:"total_bytes"`
+	Free        uint64        `json:"free_bytes"` // available to unprivileged users
+	InodesUsed  uint64        `json:"inodes_used,omitempty"`
+	InodesTotal uint64        `json:"inodes_total,omitempty"`
+	FullIn      time.Duration `json:"-"` // forecast time until full; 0 if not filling
+}
+
+// InodePercent returns used inodes as a percentage of total.
+func (d DiskUsage) InodePercent() float64 {
+	if d.InodesTotal == 0 {
+		return 0
+	}
+	return float64(d.InodesUsed) / float64(d.InodesTotal) * 100
+}
+
+func (d DiskUsage) MarshalJSON() ([]byte, error) {
+	type plain DiskUsage
+	return json.Marshal(struct {
+		plain
+		FullInSeconds float64 `json:"full_in_seconds,omitempty"`
+	}{plain(d), d.FullIn.Seconds()})
+}
+
+// Percent returns used space as a percentage of usable space, as df does:
+// blocks reserved for root are not counted as available.
+func (d DiskUsage) Percent() float64 {
+	if d.Used+d.Free == 0 {
+		return 0
+	}
+	return float64(d.Used) / float64(d.Used+d.Free) * 100
+}
+
+// MemPercent returns used memory as a percentage of total.
+func (m *Metrics) MemPercent() float64 {
+	if m.MemTotal == 0 {
+		return 0
+	}
+	return float64(m.MemUsed) / float64(m.MemTotal) * 100
+}
+
+// RootDisk returns the usage of "/" or, failing that, the first mount.
+func (m *Metrics) RootDisk() (DiskUsage, bool) {
+	for _, d := range m.Disks {
+		if d.Mount == "/" {
+			return d, true
+		}
+	}
+	if len(m.Disks) > 0 {
+		return m.Disks[0], true
+	}
+	return DiskUsage{}, false
+}
+
+func (m *Metrics) LoadString() string {
+	if m == nil || !m.HasLoad {
+		return ""
+	}
+	return fmt.Sprintf("%.2f %.2f %.2f", m.Load1, m.Load5, m.Load15)
+}
+
+func (m *Metrics) MemoryString() string {
+	if m == nil || m.MemTotal == 0 {
+		return ""
+	}
+	return formatBytes(m.MemUsed) + "/" + formatBytes(m.MemTotal)
+}
+
+func (m *Metrics) DiskString() string {
+	if m == nil {
+		return ""
+	}
+	d, ok := m.RootDisk()
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%.0f%%", d.Percent())
+}
+
+func (m *Metrics) UptimeString() string {
+	if m == nil || m.Uptime <= 0 {
+		return ""
+	}
+	return formatDuration(m.Uptime)
+}
+
+// loadJSON carries the load averages, left out entirely when a host has
+// no load reading so that a zero is never mistaken for one.
+type loadJSON struct {
+	Load1  *float64 `json:"load1,omitempty"`
+	Load5  *float64 `json:"load5,omitempty"`
+	Load15 *float64 `json:"load15,omitempty"`
+}
+
+func (m Metrics) MarshalJSON() ([]byte, error) {
+	type plain Metrics
+	var load loadJSON
+	if m.HasLoad {
+		load = loadJSON{&m.Load1, &m.Load5, &m.Load15}
+	}
+	return json.Marshal(struct {
+		plain
+		loadJSON
+		UptimeSeconds float64 `json:"uptime_seconds,omitempty"`
+	}{plain(m), load, m.Uptime.Seconds()})
+}
+
+func (m *Metrics) UnmarshalJSON(data []byte) error {
+	type plain Metrics
+	var v struct {
+		plain
+		loadJSON
+		UptimeSeconds float64 `json:"uptime_seconds"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*m = Metrics(v.plain)
+	if l := v.loadJSON; l.Load1 != nil {
+		m.Load1 = *l.Load1
+		m.HasLoad = true
+		if l.Load5 != nil {
+			m.Load5 = *l.Load5
+		}
+		if l.Load15 != nil {
+			m.Load15 = *l.Load15
+		}
+	}
+	m.Uptime = time.Duration(v.UptimeSeconds * float64(time.Second))
+	return nil
+}
+
+// formatBytes renders a byte count with binary units, like `free -h`.
+func formatBytes(b uint64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%dB", b)
+	}
+	div, exp := uint64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ci", float64(b)/float64(div), "KMGTPE"[exp])
+}
+
+// formatDuration renders an uptime as e.g. "3d 4h" or "12m".
+func formatDuration(d time.Duration) string {
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	mins := int(d.Minutes()) % 60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, mins)
+	default:
+		return fmt.Sprintf("%dm", mins)
+	}
+}
+
+// parseLoad reads "0.52 0.48 0.40 ..." from /proc/loadavg or "{ 0.52 0.48 0.40 }"
+// from sysctl vm.loadavg.
+func parseLoad(out string, m *Metrics) bool {
+	parts := strings.Fields(strings.Trim(strings.TrimSpace(out), "{ }"))
+	if len(parts) < 3 {
+		return false
+	}
+	var vals [3]float64
+	for i := range vals {
+		v, err := strconv.ParseFloat(parts[i], 64)
+		if err != nil {
+			return false
+		}
+		vals[i] = v
+	}
+	m.Load1, m.Load5, m.Load15 = vals[0], vals[1], vals[2]
+	m.HasLoad = true
+	return true
+}
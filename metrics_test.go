@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsJSONLoad(t *testing.T) {
+	tests := []struct {
+		name string
+		m    Metrics
+		load string // LoadString after a round trip
+	}{
+		{"no load reading", Metrics{MemUsed: 1, MemTotal: 2, Uptime: time.Hour}, ""},
+		{"idle", Metrics{HasLoad: true}, "0.00 0.00 0.00"},
+		{"busy", Metrics{Load1: 1.5, Load5: 0.75, Load15: 0.25, HasLoad: true}, "1.50 0.75 0.25"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if has := strings.Contains(string(data), `"load1"`); has != tt.m.HasLoad {
+				t.Errorf("JSON %s: load1 present = %v, want %v", data, has, tt.m.HasLoad)
+			}
+			var got Metrics
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.HasLoad != tt.m.HasLoad || got.LoadString() != tt.load || got.Uptime != tt.m.Uptime {
+				t.Errorf("round trip = %+v, want %+v", got, tt.m)
+			}
+		})
+	}
+}
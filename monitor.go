@@ -0,0 +1,31 @@
+package main
+
+// monitor is what pulse remembers between checks: pooled SSH connections,
+// which hosts are being checked, and per host the previous result, the disk
+// usage behind the fill forecast, the last update check and the last
+// heartbeat push. Each run mode makes one and passes it to every check it
+// runs.
+type monitor struct {
+	pool       *sshPool
+	scheduler  *scheduler
+	results    *resultStore
+	disks      *diskTrends
+	updates    *updateStore
+	heartbeats *heartbeatStore
+}
+
+func newMonitor() *monitor {
+	return &monitor{
+		pool:       newSSHPool(),
+		scheduler:  newScheduler(),
+		results:    newResultStore(),
+		disks:      newDiskTrends(),
+		updates:    newUpdateStore(),
+		heartbeats: newHeartbeatStore(),
+	}
+}
+
+// close drops the pooled connections.
+func (mon *monitor) close() {
+	mon.pool.CloseAll()
+}
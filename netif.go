@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NetInterface holds cumulative counters for one interface and the rates
+// derived from the previous check of the same host.
+type NetInterface struct {
+	Name      string `json:"name"`
+	RxBytes   uint64 `json:"rx_bytes"`
+	TxBytes   uint64 `json:"tx_bytes"`
+	RxErrors  uint64 `json:"rx_errors"`
+	TxErrors  uint64 `json:"tx_errors"`
+	RxDropped uint64 `json:"rx_dropped"`
+	TxDropped uint64 `json:"tx_dropped"`
+
+	// Per-second rates; zero on the first check or after a counter reset.
+	RxRate    float64 `json:"rx_bytes_per_sec"`
+	TxRate    float64 `json:"tx_bytes_per_sec"`
+	ErrorRate float64 `json:"errors_per_sec"` // rx + tx errors
+	DropRate  float64 `json:"drops_per_sec"`  // rx + tx drops
+	HasRates  bool    `json:"-"`
+}
+
+// netProbe dumps /proc/net/dev on Linux and netstat -ibdn elsewhere; -d
+// adds the drop counters, and systems without it fall back to -ibn.
+const netProbe = `
+if [ -r /proc/net/dev ]; then
+	sed -n '3,$p' /proc/net/dev | sed 's/^/netdev=/'
+else
+	{ netstat -ibdn 2>/dev/null || netstat -ibn; } | sed 's/^/netstat=/'
+fi
+`
+
+// parseNetDev parses /proc/net/dev rows:
+// "eth0: rxbytes rxpkts rxerrs rxdrop fifo frame compressed multicast txbytes txpkts txerrs txdrop ...".
+func parseNetDev(lines []string) []NetInterface {
+	var out []NetInterface
+	for _, line := range lines {
+		name, rest, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		f := strings.Fields(rest)
+		if len(f) < 12 {
+			continue
+		}
+		v := make([]uint64, 12)
+		for i := range v {
+			v[i], _ = strconv.ParseUint(f[i], 10, 64)
+		}
+		out = append(out, NetInterface{
+			Name:    strings.TrimSpace(name),
+			RxBytes: v[0], RxErrors: v[2], RxDropped: v[3],
+			TxBytes: v[8], TxErrors: v[10], TxDropped: v[11],
+		})
+	}
+	return out
+}
+
+// parseNetstat parses `netstat -ibdn` (macOS/BSD), using only the <Link#N>
+// row of each interface. Those rows may omit the Address column, which is
+// detected by the field count. Idrop (FreeBSD) counts input drops and Drop
+// output queue drops.
+func parseNetstat(lines []string) []NetInterface {
+	if len(lines) < 2 {
+		return nil
+	}
+	header := strings.Fields(lines[0])
+	col := make(map[string]int)
+	for i, h := range header {
+		col[h] = i
+	}
+	addrCol, ok := col["Address"]
+	if !ok {
+		return nil
+	}
+	var out []NetInterface
+	seen := make(map[string]bool)
+	for _, line := range lines[1:] {
+		f := strings.Fields(line)
+		if len(f) < 2 || !strings.HasPrefix(f[col["Network"]], "<Link") || seen[f[0]] {
+			continue
+		}
+		if len(f) == len(header)-1 {
+			f = append(f[:addrCol], append([]string{""}, f[addrCol:]...)...)
+		}
+		get := func(name string) uint64 {
+			i, ok := col[name]
+			if !ok || i >= len(f) {
+				return 0
+			}
+			v, _ := strconv.ParseUint(f[i], 10, 64)
+			return v
+		}
+		seen[f[0]] = true
+		out = append(out, NetInterface{
+			Name:    f[0],
+			RxBytes: get("Ibytes"), RxErrors: get("Ierrs"),
+			TxBytes: get("Obytes"), TxErrors: get("Oerrs"),
+			RxDropped: get("Idrop"), TxDropped: get("Drop"),
+		})
+	}
+	return out
+}
+
+// filterInterfaces keeps interfaces matching the host's globs, or by default
+// everything except loopback, container veth pairs and interfaces that have
+// never carried traffic.
+func filterInterfaces(ifaces []NetInterface, patterns []string) []NetInterface {
+	var out []NetInterface
+	for _, ni := range ifaces {
+		keep := len(patterns) == 0 && ni.RxBytes+ni.TxBytes > 0 &&
+			!strings.HasPrefix(ni.Name, "lo") && !strings.HasPrefix(ni.Name, "veth")
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, ni.Name); ok {
+				keep = true
+			}
+		}
+		if keep {
+			out = append(out, ni)
+		}
+	}
+	return out
+}
+
+// netRates derives per-second rates from the previous counters of the same
+// interface. Counters that went backwards (reboot, wrap) yield no rate.
+func netRates(cur []NetInterface, prev []NetInterface, elapsed time.Duration) {
+	if elapsed <= 0 {
+		return
+	}
+	byName := make(map[string]NetInterface)
+	for _, p := range prev {
+		byName[p.Name] = p
+	}
+	secs := elapsed.Seconds()
+	for i := range cur {
+		c := &cur[i]
+		p, ok := byName[c.Name]
+		if !ok || c.RxBytes < p.RxBytes || c.TxBytes < p.TxBytes ||
+			c.RxErrors+c.TxErrors < p.RxErrors+p.TxErrors || c.RxDropped+c.TxDropped < p.RxDropped+p.TxDropped {
+			continue
+		}
+		c.RxRate = float64(c.RxBytes-p.RxBytes) / secs
+		c.TxRate = float64(c.TxBytes-p.TxBytes) / secs
+		c.ErrorRate = float64(c.RxErrors+c.TxErrors-p.RxErrors-p.TxErrors) / secs
+		c.DropRate = float64(c.RxDropped+c.TxDropped-p.RxDropped-p.TxDropped) / secs
+		c.HasRates = true
+	}
+}
+
+// formatRate renders a byte rate, e.g. "1.2Mi/s".
+func formatRate(r float64) string {
+	return formatBytes(uint64(r)) + "/s"
+}
+
+// describe renders the interface rates for display.
+func (ni NetInterface) describe() string {
+	if !ni.HasRates {
+		return "…"
+	}
+	d := fmt.Sprintf("↓%s ↑%s", formatRate(ni.RxRate), formatRate(ni.TxRate))
+	if ni.ErrorRate > 0 || ni.DropRate > 0 {
+		d += fmt.Sprintf("  err %.1f/s drop %.1f/s", ni.ErrorRate, ni.DropRate)
+	}
+	return d
+}
+
+// resultStore keeps each host's last HostStatus, whose counters the next
+// check turns into rates with netRates.
+type resultStore struct {
+	mu   sync.Mutex
+	last map[string]HostStatus
+}
+
+func newResultStore() *resultStore {
+	return &resultStore{last: make(map[string]HostStatus)}
+}
+
+// swap stores s as the latest result for its host and returns the previous one.
+func (rs *resultStore) swap(s HostStatus) (HostStatus, bool) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	prev, ok := rs.last[s.Config.Name]
+	rs.last[s.Config.Name] = s
+	return prev, ok
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseNetDev(t *testing.T) {
+	// /proc/net/dev from line 3 on, as netProbe sends it.
+	lines := strings.Split(`    lo: 4915820   21804    0    0    0     0          0         0  4915820   21804    0    0    0     0       0          0
+  eth0: 1851432915 1526839  3   12    0     0          0      1042 120839502  700520    1    5    0     0       0          0
+wlp2s0:       0       0    0    0    0     0          0         0        0       0    0    0    0     0       0          0
+ bogus: 1 2 3`, "\n")
+	want := []NetInterface{
+		{Name: "lo", RxBytes: 4915820, TxBytes: 4915820},
+		{Name: "eth0", RxBytes: 1851432915, RxErrors: 3, RxDropped: 12, TxBytes: 120839502, TxErrors: 1, TxDropped: 5},
+		{Name: "wlp2s0"},
+	}
+	if got := parseNetDev(lines); !slices.Equal(got, want) {
+		t.Errorf("parseNetDev =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestParseNetstat(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want []NetInterface
+	}{
+		{
+			// macOS: -d adds Drop (output queue drops); loopback has no address.
+			name: "darwin",
+			out: `Name       Mtu   Network       Address            Ipkts Ierrs     Ibytes    Opkts Oerrs     Obytes  Coll Drop
+lo0        16384 <Link#1>                         21804     0    4915820    21804     0    4915820     0    0
+lo0        16384 127           127.0.0.1          21804     -    4915820    21804     -    4915820     -    -
+en0        1500  <Link#11>   3c:22:fb:01:02:03  1526839     4 1851432915   700520     2  120839502     0    7
+en0        1500  fe80::1c1f: fe80:b::1c1f:aaaa  1526839     - 1851432915   700520     -  120839502     -    -
+utun0      1380  <Link#15>                            0     0          0        3     0        232     0    0`,
+			want: []NetInterface{
+				{Name: "lo0", RxBytes: 4915820, TxBytes: 4915820},
+				{Name: "en0", RxBytes: 1851432915, RxErrors: 4, TxBytes: 120839502, TxErrors: 2, TxDropped: 7},
+				{Name: "utun0", TxBytes: 232},
+			},
+		},
+		{
+			// FreeBSD: Idrop is always there, -d adds Drop.
+			name: "freebsd",
+			out: `Name    Mtu Network       Address              Ipkts Ierrs Idrop     Ibytes    Opkts Oerrs     Obytes  Coll Drop
+em0    1500 <Link#1>      08:00:27:9a:4b:11    52006     1     9   58423180    25339     0    2361510     0    3
+em0       - 10.0.2.0/24   10.0.2.15            51980     -     -   57682596    25333     -    2006926     -    -
+lo0   16384 <Link#2>      lo0                     48     0     0       3272       48     0       3272     0    0`,
+			want: []NetInterface{
+				{Name: "em0", RxBytes: 58423180, RxErrors: 1, RxDropped: 9, TxBytes: 2361510, TxDropped: 3},
+				{Name: "lo0", RxBytes: 3272, TxBytes: 3272},
+			},
+		},
+		{
+			// Without -d (the fallback) there are no output drops.
+			name: "freebsd without -d",
+			out: `Name    Mtu Network       Address              Ipkts Ierrs Idrop     Ibytes    Opkts Oerrs     Obytes  Coll
+em0    1500 <Link#1>      08:00:27:9a:4b:11    52006     1     9   58423180    25339     0    2361510     0`,
+			want: []NetInterface{
+				{Name: "em0", RxBytes: 58423180, RxErrors: 1, RxDropped: 9, TxBytes: 2361510},
+			},
+		},
+		{name: "header only", out: "Name Mtu Network Address Ipkts Ierrs Ibytes Opkts Oerrs Obytes Coll"},
+		{name: "not netstat", out: "netstat: illegal option -- d\nusage: netstat"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseNetstat(strings.Split(tt.out, "\n")); !slices.Equal(got, tt.want) {
+				t.Errorf("parseNetstat =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNetRates(t *testing.T) {
+	prev := []NetInterface{
+		{Name: "eth0", RxBytes: 1000, TxBytes: 500, RxErrors: 1, TxDropped: 2},
+		{Name: "wlan0", RxBytes: 9000, TxBytes: 9000},
+	}
+	cur := []NetInterface{
+		{Name: "eth0", RxBytes: 21000, TxBytes: 2500, RxErrors: 3, TxDropped: 6},
+		{Name: "wlan0", RxBytes: 100, TxBytes: 100}, // reset by a reboot
+		{Name: "eth1", RxBytes: 100},                // new
+	}
+	netRates(cur, prev, 10*time.Second)
+	if e := cur[0]; !e.HasRates || e.RxRate != 2000 || e.TxRate != 200 || e.ErrorRate != 0.2 || e.DropRate != 0.4 {
+		t.Errorf("eth0 = %+v, want rx 2000/s, tx 200/s, 0.2 errors/s, 0.4 drops/s", e)
+	}
+	for _, ni := range cur[1:] {
+		if ni.HasRates {
+			t.Errorf("%s = %+v, want no rates", ni.Name, ni)
+		}
+	}
+}
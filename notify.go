@@ -5,73 +5,269 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
 	"os/exec"
+	"slices"
 	"strings"
 	"time"
 )
 
-// StateTracker tracks host online/offline transitions and fires notifications.
+// StateTracker tracks host state transitions (down, ok, warning, critical)
+// and those of individual components such as services, and fires
+// notifications.
 type StateTracker struct {
-	prev   map[string]bool // host -> was online
-	config NotifyConfig
+	prev      map[string]string            // host name -> last HostStatus.State()
+	prevComps map[string]map[string]string // host name -> component -> last state
+	failure   map[string]FailureReason     // host name -> why it is down
+	pending   map[string]PendingState      // host name -> unconfirmed new state
+	config    NotifyConfig
+}
+
+// PendingState is a host state seen in recent checks but not yet confirmed
+// by enough consecutive results (HostConfig.ConfirmDown / ConfirmUp).
+type PendingState struct {
+	State string `json:"state"`
+	Seen  int    `json:"seen"`
+	Need  int    `json:"need"`
+}
+
+// confirmations returns how many consecutive results in a row are needed
+// before a change from was to now is announced. Going down and coming back
+// up use the host's settings; key mismatches and health changes are
+// immediate.
+func confirmations(hc HostConfig, was, now string) int {
+	switch {
+	case now == "down":
+		return hc.ConfirmDown
+	case was == "down" && now != "key_mismatch":
+		return hc.ConfirmUp
+	default:
+		return 1
+	}
+}
+
+// event is a single state change handed to the notifiers.
+type event struct {
+	Host      HostConfig
+	Component string // empty for the host itself, e.g. "service nginx"
+	State     string
+	Reason    string
+	Failure   FailureReason // why the host is down, or was down for "up"
+}
+
+// component is the state of one part of a host that is tracked on its own.
+type component struct {
+	state  string
+	detail string
+}
+
+// components returns the separately tracked parts of a host keyed by name.
+func (s HostStatus) components() map[string]component {
+	comps := make(map[string]component)
+	for _, svc := range s.Services {
+		comps["service "+svc.Name] = component{string(svc.Health), svc.describe()}
+	}
+	for _, c := range s.Containers {
+		if c.Pinned {
+			comps["container "+c.Name] = component{string(c.health()), c.describe()}
+		}
+	}
+	for _, c := range s.Checks {
+		comps["check "+c.Name] = component{string(c.Health), c.Message}
+	}
+	return comps
 }
 
 func NewStateTracker(cfg NotifyConfig) *StateTracker {
 	return &StateTracker{
-		prev:   make(map[string]bool),
-		config: cfg,
+		prev:      make(map[string]string),
+		prevComps: make(map[string]map[string]string),
+		failure:   make(map[string]FailureReason),
+		pending:   make(map[string]PendingState),
+		config:    cfg,
 	}
 }
 
-// Update checks for state changes and fires notifications. Returns list of transitions.
+// Update checks for state changes and fires notifications. Returns list of
+// transitions. Results whose change is not yet confirmed get Pending set.
 func (st *StateTracker) Update(results []HostStatus) []string {
 	var transitions []string
+	for i := range results {
+		r := &results[i]
+		r.Pending = nil
+		if r.LastCheck.IsZero() {
+			continue
+		}
+		key := r.Config.Name
+		was, seen := st.prev[key]
+		now := r.State()
+		if !seen || was == now {
+			st.prev[key] = now
+			delete(st.pending, key)
+			continue // first check or no change
+		}
+		p := st.pending[key]
+		if p.State != now {
+			p = PendingState{State: now, Need: confirmations(r.Config, was, now)}
+		}
+		p.Seen++
+		if p.Seen < p.Need {
+			st.pending[key] = p
+			r.Pending = &p
+			continue
+		}
+		delete(st.pending, key)
+		st.prev[key] = now
+		reason := strings.Join(r.Reasons, ", ")
+		failure := r.Failure
+		var msg, state string
+		switch {
+		case now == "down":
+			msg, state, reason = "went DOWN", "down", r.Error
+		case now == "key_mismatch":
+			msg, state, reason = "HOST KEY MISMATCH", now, r.Error
+		case was == "down" || was == "key_mismatch":
+			msg, state, failure = "came UP", "up", st.failure[key]
+		default:
+			msg, state = "is now "+strings.ToUpper(now), now
+		}
+		if !r.Online {
+			st.failure[key] = r.Failure
+			if r.Failure != "" {
+				msg += " (" + string(r.Failure) + ")"
+			}
+		} else if reason != "" && now != "ok" {
+			msg += " (" + reason + ")"
+		}
+		transitions = append(transitions, fmt.Sprintf("%s (%s) %s", r.Config.Label, r.Config.Host, msg))
+		go st.notify(event{Host: r.Config, State: state, Reason: reason, Failure: failure})
+	}
 	for _, r := range results {
-		key := r.Config.Host
-		wasOnline, seen := st.prev[key]
-		st.prev[key] = r.Online
+		if r.Online {
+			transitions = append(transitions, st.updateComponents(r)...)
+		}
+	}
+	return transitions
+}
 
-		if !seen {
-			continue // first check, no transition
+// updateComponents fires transitions for components of an online host.
+// Components of offline hosts keep their last state so a host coming back
+// does not re-announce everything.
+func (st *StateTracker) updateComponents(r HostStatus) []string {
+	var transitions []string
+	prev := st.prevComps[r.Config.Name]
+	if prev == nil {
+		prev = make(map[string]string)
+		st.prevComps[r.Config.Name] = prev
+	}
+	for name, c := range r.components() {
+		was, seen := prev[name]
+		prev[name] = c.state
+		if !seen || was == c.state {
+			continue
 		}
-		if wasOnline && !r.Online {
-			msg := fmt.Sprintf("%s (%s) went DOWN", r.Config.Label, r.Config.Host)
-			transitions = append(transitions, msg)
-			go st.notify(r.Config, "down")
-		} else if !wasOnline && r.Online {
-			msg := fmt.Sprintf("%s (%s) came UP", r.Config.Label, r.Config.Host)
-			transitions = append(transitions, msg)
-			go st.notify(r.Config, "up")
+		msg := fmt.Sprintf("%s (%s) %s is now %s", r.Config.Label, r.Config.Host, name, strings.ToUpper(c.state))
+		if c.detail != "" {
+			msg += " (" + c.detail + ")"
 		}
+		transitions = append(transitions, msg)
+		go st.notify(event{Host: r.Config, Component: name, State: c.state, Reason: c.detail})
 	}
 	return transitions
 }
 
-func (st *StateTracker) notify(hc HostConfig, state string) {
-	if st.config.Webhook != "" {
-		st.webhookNotify(hc, state)
+func (st *StateTracker) notify(ev event) {
+	if st.config.Webhook != "" && routed(st.config.WebhookFailures, ev) {
+		st.webhookNotify(ev)
 	}
-	if st.config.Command != "" {
-		st.commandNotify(hc, state)
+	if st.config.Command != "" && routed(st.config.CommandFailures, ev) {
+		st.commandNotify(ev)
 	}
 }
 
-func (st *StateTracker) webhookNotify(hc HostConfig, state string) {
+// routed reports whether a notifier limited to the given failure reasons
+// takes ev. Events not caused by a failure always pass.
+func routed(only []FailureReason, ev event) bool {
+	return len(only) == 0 || ev.Failure == "" || slices.Contains(only, ev.Failure)
+}
+
+func (st *StateTracker) webhookNotify(ev event) {
 	payload := map[string]string{
-		"host":  hc.Host,
-		"label": hc.Label,
-		"state": state,
-		"time":  time.Now().Format(time.RFC3339),
+		"host":      ev.Host.Host,
+		"label":     ev.Host.Label,
+		"component": ev.Component,
+		"state":     ev.State,
+		"reason":    ev.Reason,
+		"failure":   string(ev.Failure),
+		"time":      time.Now().Format(time.RFC3339),
 	}
 	body, _ := json.Marshal(payload)
 	client := &http.Client{Timeout: 10 * time.Second}
 	client.Post(st.config.Webhook, "application/json", bytes.NewReader(body)) //nolint:errcheck
 }
 
-func (st *StateTracker) commandNotify(hc HostConfig, state string) {
-	cmd := st.config.Command
-	cmd = strings.ReplaceAll(cmd, "{host}", hc.Host)
-	cmd = strings.ReplaceAll(cmd, "{label}", hc.Label)
-	cmd = strings.ReplaceAll(cmd, "{state}", state)
-	exec.Command("sh", "-c", cmd).Run() //nolint:errcheck
+// notifyVars are the {placeholders} of a notify command, each also passed
+// as PULSE_<NAME> in the environment.
+var notifyVars = []string{"host", "label", "component", "state", "reason", "failure"}
+
+// commandNotify runs the notify command with the event in PULSE_HOST,
+// PULSE_LABEL, PULSE_COMPONENT, PULSE_STATE, PULSE_REASON and PULSE_FAILURE.
+// Text from hosts only reaches the shell through those variables.
+func (st *StateTracker) commandNotify(ev event) {
+	values := []string{ev.Host.Host, ev.Host.Label, ev.Component, ev.State, ev.Reason, string(ev.Failure)}
+	env := os.Environ()
+	for i, name := range notifyVars {
+		env = append(env, "PULSE_"+strings.ToUpper(name)+"="+values[i])
+	}
+	cmd := exec.Command("sh", "-c", quoteTemplate(st.config.Command))
+	cmd.Env = env
+	cmd.Run() //nolint:errcheck
+}
+
+// quoteTemplate replaces each {placeholder} in a notify command with a
+// reference to its PULSE_ variable, quoted for where it stands: bare, or
+// inside single or double quotes. The shell expands the value but never
+// parses it.
+func quoteTemplate(cmd string) string {
+	var b strings.Builder
+	var quote byte // ' or " while inside quotes
+	for i := 0; i < len(cmd); i++ {
+		if cmd[i] == '{' {
+			if name, ok := placeholderAt(cmd[i:]); ok {
+				ref := "${PULSE_" + strings.ToUpper(name) + "}"
+				switch quote {
+				case 0:
+					ref = `"` + ref + `"`
+				case '\'':
+					ref = `'"` + ref + `"'`
+				}
+				b.WriteString(ref)
+				i += len(name) + 1
+				continue
+			}
+		}
+		c := cmd[i]
+		switch {
+		case c == '\\' && quote != '\'' && i+1 < len(cmd):
+			b.WriteByte(c)
+			i++
+			c = cmd[i]
+		case quote == 0 && (c == '\'' || c == '"'):
+			quote = c
+		case c == quote:
+			quote = 0
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// placeholderAt returns the notify variable whose {placeholder} s starts with.
+func placeholderAt(s string) (string, bool) {
+	for _, name := range notifyVars {
+		if strings.HasPrefix(s, "{"+name+"}") {
+			return name, true
+		}
+	}
+	return "", false
 }
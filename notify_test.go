@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStateTrackerConfirmations(t *testing.T) {
+	hc := HostConfig{Name: "web", Label: "web", Host: "10.0.0.2", ConfirmDown: 3, ConfirmUp: 2}
+	up := HostStatus{Config: hc, Online: true, Health: HealthOK}
+	down := HostStatus{Config: hc, Failure: FailureTimeout, Error: "dial: i/o timeout"}
+	mismatch := HostStatus{Config: hc, HostKeyMismatch: true, Failure: FailureHostKey}
+	warn := HostStatus{Config: hc, Online: true, Health: HealthWarning, Reasons: []string{"load 5.0"}}
+
+	steps := []struct {
+		name    string
+		s       HostStatus
+		want    string // transition announced, if any
+		pending *PendingState
+	}{
+		{"first check", up, "", nil},
+		{"down 1", down, "", &PendingState{State: "down", Seen: 1, Need: 3}},
+		{"down 2", down, "", &PendingState{State: "down", Seen: 2, Need: 3}},
+		{"flap back up", up, "", nil},
+		{"down again starts over", down, "", &PendingState{State: "down", Seen: 1, Need: 3}},
+		{"down 2 again", down, "", &PendingState{State: "down", Seen: 2, Need: 3}},
+		{"down 3", down, "went DOWN (timeout)", nil},
+		{"still down", down, "", nil},
+		{"up 1", up, "", &PendingState{State: "ok", Seen: 1, Need: 2}},
+		{"up 2", up, "came UP", nil},
+		{"health changes at once", warn, "is now WARNING (load 5.0)", nil},
+		{"key mismatch at once", mismatch, "HOST KEY MISMATCH (host_key_mismatch)", nil},
+	}
+	st := NewStateTracker(NotifyConfig{})
+	now := time.Now()
+	for i, step := range steps {
+		step.s.LastCheck = now.Add(time.Duration(i) * time.Minute)
+		results := []HostStatus{step.s}
+		transitions := st.Update(results)
+		got := strings.Join(transitions, "; ")
+		if step.want == "" && got != "" || step.want != "" && !strings.HasSuffix(got, step.want) {
+			t.Errorf("%s: transitions %q, want %q", step.name, got, step.want)
+		}
+		p := results[0].Pending
+		if (p == nil) != (step.pending == nil) || p != nil && *p != *step.pending {
+			t.Errorf("%s: pending %+v, want %+v", step.name, p, step.pending)
+		}
+	}
+}
+
+func TestConfirmations(t *testing.T) {
+	hc := HostConfig{ConfirmDown: 3, ConfirmUp: 2}
+	tests := []struct {
+		was, now string
+		want     int
+	}{
+		{"ok", "down", 3},
+		{"warning", "down", 3},
+		{"down", "ok", 2},
+		{"down", "critical", 2},
+		{"down", "key_mismatch", 1},
+		{"key_mismatch", "ok", 1},
+		{"ok", "warning", 1},
+	}
+	for _, tt := range tests {
+		if got := confirmations(hc, tt.was, tt.now); got != tt.want {
+			t.Errorf("confirmations(%s -> %s) = %d, want %d", tt.was, tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestCommandNotifyQuotesValues(t *testing.T) {
+	dir := t.TempDir()
+	hostile := `x'; touch ` + dir + `/PWNED; echo '$(touch ` + dir + `/PWNED)` + "`touch " + dir + "/PWNED`"
+	ev := event{
+		Host:    HostConfig{Host: "10.0.0.2", Label: "web"},
+		State:   "down",
+		Reason:  hostile,
+		Failure: FailureCommand,
+	}
+	tests := []struct {
+		name, command, want string
+	}{
+		{"bare", "printf '%s|%s|%s\\n' {label} {state} {reason} > " + dir + "/out", "web|down|" + hostile},
+		{"inside double quotes", `echo "{label}: {reason}" > ` + dir + "/out", "web: " + hostile},
+		{"inside single quotes", `echo '{label} {state}: {reason}' > ` + dir + "/out", "web down: " + hostile},
+		{"after escaped quotes", `echo \'{label}\" {reason} > ` + dir + "/out", `'web" ` + hostile},
+		{"environment", `echo "$PULSE_HOST $PULSE_FAILURE $PULSE_REASON" > ` + dir + "/out", "10.0.0.2 command_failed " + hostile},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := NewStateTracker(NotifyConfig{Command: tt.command})
+			st.commandNotify(ev)
+			if _, err := os.Stat(filepath.Join(dir, "PWNED")); err == nil {
+				t.Fatal("the reason ran as shell")
+			}
+			out, err := os.ReadFile(filepath.Join(dir, "out"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSuffix(string(out), "\n"); got != tt.want {
+				t.Errorf("command wrote %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// waitForFile polls for a file written by a notifier running in the
+// background.
+func waitForFile(t *testing.T, path string) []byte {
+	t.Helper()
+	for range 100 {
+		if b, err := os.ReadFile(path); err == nil && len(b) > 0 {
+			return b
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("%s was never written", path)
+	return nil
+}
+
+func TestQuoteTemplate(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"notify {label} {state}", `notify "${PULSE_LABEL}" "${PULSE_STATE}"`},
+		{"say '{label} is {state}'", `say ''"${PULSE_LABEL}"' is '"${PULSE_STATE}"''`},
+		{`say "{label} is {state}"`, `say "${PULSE_LABEL} is ${PULSE_STATE}"`},
+		{`say "it's {label}" '"{label}"'`, `say "it's ${PULSE_LABEL}" '"'"${PULSE_LABEL}"'"'`},
+		{"{unknown} {host}s", `{unknown} "${PULSE_HOST}"s`},
+	}
+	for _, tt := range tests {
+		if got := quoteTemplate(tt.in); got != tt.want {
+			t.Errorf("quoteTemplate(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDownReasonFromHostIsQuoted(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	phrase := "x'; touch " + dir + "/PWNED; echo '"
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			bufio.NewReader(conn).ReadString('\n') //nolint:errcheck
+			fmt.Fprintf(conn, "HTTP/1.1 500 %s\r\nContent-Length: 0\r\nConnection: close\r\n\r\n", phrase)
+			conn.Close()
+		}
+	}()
+	cfg, err := parseConfig([]byte(fmt.Sprintf(`notify:
+  command: "echo '{label} {state}' {reason} > %s/out"
+  command_failures: [command_failed]
+hosts:
+  - {name: api, type: http, url: "http://%s/"}
+`, dir, ln.Addr())))
+	if err != nil {
+		t.Fatalf("parseConfig: %v", err)
+	}
+	hc := cfg.Hosts[0]
+	st := NewStateTracker(cfg.Notify)
+	st.Update([]HostStatus{{Config: hc, Online: true, Health: HealthOK, LastCheck: time.Now()}})
+	s := newMonitor().checkHost(t.Context(), hc)
+	if s.Online || s.Failure != FailureCommand {
+		t.Fatalf("status = %+v, want command_failed", s)
+	}
+	st.Update([]HostStatus{s})
+
+	out := waitForFile(t, filepath.Join(dir, "out"))
+	if _, err := os.Stat(filepath.Join(dir, "PWNED")); err == nil {
+		t.Fatal("the HTTP reason phrase ran as shell")
+	}
+	if want := "api down unexpected status 500 " + phrase + "\n"; string(out) != want {
+		t.Errorf("command wrote %q, want %q", out, want)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// keepaliveInterval is how often idle pooled connections are probed.
+const keepaliveInterval = 30 * time.Second
+
+// sshPool keeps one authenticated *ssh.Client per host alive between check
+// cycles so each interval doesn't pay for a fresh TCP + SSH handshake.
+type sshPool struct {
+	mu      sync.Mutex
+	clients map[string]*ssh.Client
+}
+
+func newSSHPool() *sshPool {
+	return &sshPool{clients: make(map[string]*ssh.Client)}
+}
+
+// poolKey identifies a connection by user, address and the jump chain used
+// to reach it.
+func poolKey(hc HostConfig) string {
+	key := fmt.Sprintf("%s@%s", hc.User, hc.Address())
+	for i := len(hc.jumps) - 1; i >= 0; i-- {
+		key += " via " + fmt.Sprintf("%s@%s", hc.jumps[i].User, hc.jumps[i].Address())
+	}
+	return key
+}
+
+// Get returns a live client for hc, reconnecting if the cached one is dead.
+func (p *sshPool) Get(ctx context.Context, hc HostConfig) (*ssh.Client, error) {
+	key := poolKey(hc)
+	p.mu.Lock()
+	client := p.clients[key]
+	p.mu.Unlock()
+
+	if client != nil {
+		if keepalive(client) == nil {
+			return client, nil
+		}
+		p.drop(key, client)
+	}
+
+	client, err := sshConnect(ctx, hc)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	if existing := p.clients[key]; existing != nil {
+		// Lost a race with a concurrent Get; keep the first connection.
+		p.mu.Unlock()
+		client.Close()
+		return existing, nil
+	}
+	p.clients[key] = client
+	p.mu.Unlock()
+
+	go p.watch(key, client)
+	return client, nil
+}
+
+// Invalidate closes and forgets the pooled client for hc, e.g. after a
+// session failed on it.
+func (p *sshPool) Invalidate(hc HostConfig) {
+	key := poolKey(hc)
+	p.mu.Lock()
+	client := p.clients[key]
+	p.mu.Unlock()
+	if client != nil {
+		p.drop(key, client)
+	}
+}
+
+// CloseAll closes every pooled connection.
+func (p *sshPool) CloseAll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key, c := range p.clients {
+		c.Close()
+		delete(p.clients, key)
+	}
+}
+
+func (p *sshPool) drop(key string, client *ssh.Client) {
+	p.mu.Lock()
+	if p.clients[key] == client {
+		delete(p.clients, key)
+	}
+	p.mu.Unlock()
+	client.Close()
+}
+
+// watch sends periodic keepalives and evicts the client once it stops
+// answering or is closed.
+func (p *sshPool) watch(key string, client *ssh.Client) {
+	closed := make(chan struct{})
+	go func() {
+		client.Wait() //nolint:errcheck
+		close(closed)
+	}()
+
+	ticker := time.NewTicker(keepaliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-closed:
+			p.drop(key, client)
+			return
+		case <-ticker.C:
+			if err := keepalive(client); err != nil {
+				p.drop(key, client)
+				return
+			}
+		}
+	}
+}
+
+// keepalive sends an OpenSSH keepalive request and waits up to checkTimeout
+// for the reply.
+func keepalive(client *ssh.Client) error {
+	errc := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		errc <- err
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-time.After(checkTimeout):
+		return fmt.Errorf("keepalive timed out")
+	}
+}
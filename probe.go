@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// probeHeader starts the output of every probe script. Output is one
+// key=value pair per line; keys may repeat (e.g. one df= line per mount).
+const probeHeader = "pulse-probe v1"
+
+// probeCore gathers the basic metrics on Linux, macOS and the BSDs in plain
+// POSIX sh. Byte counts are printed with printf %.0f so awk never switches
+// to exponent notation on large values.
+const probeCore = `
+os=$(uname -s)
+echo "os=$os"
+echo "kernel=$(uname -r)"
+case "$os" in
+Linux)
+	read l1 l5 l15 _ < /proc/loadavg && echo "load=$l1 $l5 $l15"
+	awk '/^MemTotal:/{t=$2} /^MemAvailable:/{a=$2} END{printf "mem_total=%.0f\nmem_used=%.0f\n", t*1024, (t-a)*1024}' /proc/meminfo
+	read up _ < /proc/uptime && echo "uptime=${up%.*}"
+	;;
+*)
+	echo "load=$(sysctl -n vm.loadavg | tr -d '{}')"
+	ps=$(sysctl -n hw.pagesize)
+	if [ "$os" = Darwin ]; then
+		echo "mem_total=$(sysctl -n hw.memsize)"
+		vm_stat | awk -v ps="$ps" '/^Pages (active|wired down|occupied by compressor):/{s+=$NF} END{printf "mem_used=%.0f\n", s*ps}'
+	else
+		echo "mem_total=$(sysctl -n hw.physmem)"
+		free=$(sysctl -n vm.stats.vm.v_free_count 2>/dev/null || sysctl -n vm.uvmexp.free 2>/dev/null)
+		inact=$(sysctl -n vm.stats.vm.v_inactive_count 2>/dev/null || echo 0)
+		[ -n "$free" ] && awk -v t="$(sysctl -n hw.physmem)" -v f="$free" -v i="$inact" -v ps="$ps" 'BEGIN{printf "mem_used=%.0f\n", t-(f+i)*ps}'
+	fi
+	boot=$(sysctl -n kern.boottime | sed 's/.*sec = \([0-9]*\).*/\1/')
+	echo "uptime=$(( $(date +%s) - boot ))"
+	;;
+esac
+df -Pk | sed 's/^/df=/'
+df -Pi | sed 's/^/dfi=/'
+`
+
+// probeScript returns the script to run for hc.
+func probeScript(hc HostConfig, updates bool) string {
+	return script(probeCore, netProbe, sensorProbe, commandProbe(hc, updates))
+}
+
+// commandProbe returns the parts of the probe that need external commands
+// (systemctl, docker, ps, the custom checks and, with updates, the package
+// manager) for hc.
+func commandProbe(hc HostConfig, updates bool) string {
+	s := serviceProbe(hc.Services) + containerProbe(hc.Containers) +
+		processProbe(hc.Processes) + customProbe(hc.Checks)
+	if updates {
+		s += updatesProbe
+	}
+	return s
+}
+
+// script wraps probe parts with the header and end marker parseProbe and
+// complete look for. Everything runs as one brace group with stdin from
+// /dev/null: sh -s reads the whole group before running any of it, so a
+// command that reads stdin can't swallow the rest of the script.
+func script(parts ...string) string {
+	var b strings.Builder
+	b.WriteString("{\necho '" + probeHeader + "'\n")
+	for _, p := range parts {
+		b.WriteString(p)
+	}
+	b.WriteString("echo probe_end=1\n} </dev/null 2>/dev/null\n")
+	return b.String()
+}
+
+// probeResult is the parsed key=value output of a probe script.
+type probeResult map[string][]string
+
+// parseProbe parses probe output. Lines before the header are ignored (login
+// banners, shell noise); lines without "=" after it are skipped.
+func parseProbe(out string) (probeResult, error) {
+	res := probeResult{}
+	seen := false
+	sc := bufio.NewScanner(strings.NewReader(out))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if !seen {
+			seen = line == probeHeader
+			continue
+		}
+		key, val, ok := strings.Cut(line, "=")
+		if !ok || key == "" {
+			continue
+		}
+		res[key] = append(res[key], strings.TrimSpace(val))
+	}
+	if !seen {
+		return nil, fmt.Errorf("probe: no %q header in output", probeHeader)
+	}
+	return res, sc.Err()
+}
+
+// complete reports whether the script ran to the end.
+func (p probeResult) complete() bool {
+	return p.get("probe_end") != ""
+}
+
+// get returns the first value for key, or "".
+func (p probeResult) get(key string) string {
+	if v := p[key]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (p probeResult) uint(key string) (uint64, bool) {
+	v, err := strconv.ParseUint(p.get(key), 10, 64)
+	return v, err == nil
+}
+
+// metrics converts the core keys into Metrics. Missing or malformed keys
+// leave the corresponding fields zero.
+func (p probeResult) metrics() *Metrics {
+	m := &Metrics{}
+	parseLoad(p.get("load"), m)
+	if total, ok := p.uint("mem_total"); ok {
+		used, _ := p.uint("mem_used")
+		m.MemUsed, m.MemTotal = used, total
+	}
+	if secs, ok := p.uint("uptime"); ok {
+		m.Uptime = time.Duration(secs) * time.Second
+	}
+	m.Disks = parseDF(p["df"], p["dfi"])
+	if lines, ok := p["netdev"]; ok {
+		m.Net = parseNetDev(lines)
+	} else {
+		m.Net = parseNetstat(p["netstat"])
+	}
+	m.Temps = parseTemps(p["zone"], p["hwmon"])
+	m.Throttled, _ = parseThrottled(p.get("throttled"))
+	m.Battery = parseBattery(p)
+	return m
+}
+
+// runScript feeds script to `sh -s` on the remote host so it runs under a
+// POSIX shell regardless of the user's login shell.
+func runScript(ctx context.Context, client *ssh.Client, script string) (string, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+	stop := context.AfterFunc(ctx, func() { session.Close() })
+	defer stop()
+
+	session.Stdin = strings.NewReader(script)
+	out, err := session.Output("sh -s")
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	return string(out), err
+}
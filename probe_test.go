@@ -0,0 +1,275 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// probeOutput joins lines into probe output, header first.
+func probeOutput(lines ...string) string {
+	return probeHeader + "\n" + strings.Join(lines, "\n") + "\n"
+}
+
+func TestParseProbe(t *testing.T) {
+	tests := []struct {
+		name     string
+		out      string
+		wantErr  bool
+		want     map[string][]string
+		complete bool
+	}{
+		{
+			name:    "missing header",
+			out:     "os=Linux\nprobe_end=1\n",
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			out:     "",
+			wantErr: true,
+		},
+		{
+			name:     "banner before header",
+			out:      "Welcome to Ubuntu\nos=SunOS\nLast login: today\n" + probeOutput("os=Linux", "probe_end=1"),
+			want:     map[string][]string{"os": {"Linux"}, "probe_end": {"1"}},
+			complete: true,
+		},
+		{
+			name:     "crlf line endings",
+			out:      probeHeader + "\r\nos=Linux\r\nkernel=6.1.0\r\nprobe_end=1\r\n",
+			want:     map[string][]string{"os": {"Linux"}, "kernel": {"6.1.0"}, "probe_end": {"1"}},
+			complete: true,
+		},
+		{
+			name:     "repeated keys and values with =",
+			out:      probeOutput("df=a", "df=b", "check=x rc=0", "no equals sign", "=orphan", "probe_end=1"),
+			want:     map[string][]string{"df": {"a", "b"}, "check": {"x rc=0"}, "probe_end": {"1"}},
+			complete: true,
+		},
+		{
+			name: "cut off before probe_end",
+			out:  probeOutput("os=Linux", "load=0.1 0.2 0.3"),
+			want: map[string][]string{"os": {"Linux"}, "load": {"0.1 0.2 0.3"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := parseProbe(tt.out)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseProbe: got %v, want error", p)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseProbe: %v", err)
+			}
+			if len(p) != len(tt.want) {
+				t.Errorf("got keys %v, want %v", p, tt.want)
+			}
+			for k, v := range tt.want {
+				if !slices.Equal(p[k], v) {
+					t.Errorf("%s = %q, want %q", k, p[k], v)
+				}
+			}
+			if p.complete() != tt.complete {
+				t.Errorf("complete() = %v, want %v", p.complete(), tt.complete)
+			}
+		})
+	}
+}
+
+const linuxProbe = `os=Linux
+kernel=6.1.0-18-amd64
+load=0.52 0.48 0.40
+mem_total=8254296064
+mem_used=2147483648
+uptime=93784
+df=Filesystem     1024-blocks     Used Available Capacity Mounted on
+df=/dev/nvme0n1p2   490617784 98123556 367498452      22% /
+df=tmpfs             4030416        0   4030416       0% /dev/shm
+df=/dev/nvme0n1p1     523248     6220    517028       2% /boot/efi
+df=/dev/loop3          56704    56704         0     100% /snap/core18/2812
+df=/dev/nvme0n1p2   490617784 98123556 367498452      22% /var/lib/docker
+dfi=Filesystem       Inodes   IUsed    IFree IUse% Mounted on
+dfi=/dev/nvme0n1p2 31211520 1034512 30177008    4% /
+dfi=/dev/nvme0n1p1        0       0        0     - /boot/efi
+netdev=    lo:    1000      10    0    0    0     0          0         0     1000      10    0    0    0     0       0          0
+netdev=  eth0: 123456789  100000    1    2    0     0          0       300   987654   90000    3    4    0     0       0          0
+zone=x86_pkg_temp 52000
+hwmon=coretemp/Package id 0 53000
+battery=81 Discharging
+probe_end=1`
+
+const darwinProbe = `os=Darwin
+kernel=23.4.0
+load=1.52 1.61 1.70
+mem_total=17179869184
+mem_used=9663676416
+uptime=432000
+df=Filesystem     1024-blocks      Used Available Capacity  Mounted on
+df=/dev/disk3s1s1   482797652  10107416 227394088     5%    /
+df=devfs                  205       205         0   100%    /dev
+df=/dev/disk3s6     482797652   2097172 227394088     1%    /System/Volumes/VM
+df=/dev/disk3s5     482797652 240000000 227394088    52%    /System/Volumes/Data
+df=map auto_home            0         0         0   100%    /System/Volumes/Data/home
+dfi=Filesystem     512-blocks      Used Available Capacity iused      ifree %iused  Mounted on
+dfi=/dev/disk3s1s1  965595304  20214832 454788176     5%  404167 2273940880    0%   /
+dfi=/dev/disk3s5    965595304 480000000 454788176    52% 2100000 2273940880    0%   /System/Volumes/Data
+netstat=Name       Mtu   Network       Address            Ipkts Ierrs     Ibytes    Opkts Oerrs     Obytes  Coll Drop
+netstat=lo0        16384 <Link#1>                         21804     0    4915820    21804     0    4915820     0    0
+netstat=en0        1500  <Link#11>   3c:22:fb:01:02:03  1526839     0 1851432915   700520     0  120839502     0    7
+netstat=en0        1500  fe80::1c1f: fe80:b::1c1f:aaaa  1526839     - 1851432915   700520     -  120839502     -    -
+pmset=Now drawing from 'Battery Power'
+pmset= -InternalBattery-0 (id=4653155)	64%; discharging; 5:12 remaining present: true
+probe_end=1`
+
+const freebsdProbe = `os=FreeBSD
+kernel=14.0-RELEASE
+load={ 0.10 0.20 0.30 }
+mem_total=4253478912
+mem_used=1073741824
+uptime=3600
+df=Filesystem         1024-blocks    Used    Avail Capacity  Mounted on
+df=/dev/ada0p2           19279260 4863540 12873384    27%    /
+df=devfs                        1       1        0   100%    /dev
+df=zroot/usr/home        90000000 1000000 89000000     1%    /usr/home
+dfi=Filesystem   512-blocks    Used    Avail Capacity iused   ifree %iused  Mounted on
+dfi=/dev/ada0p2    38558520 9727080 25746768    27%  214233 2389637    8%   /
+netstat=Name    Mtu Network       Address              Ipkts Ierrs Idrop     Ibytes    Opkts Oerrs     Obytes  Coll Drop
+netstat=em0    1500 <Link#1>      08:00:27:9a:4b:11    52006     0     0   58423180    25339     0    2361510     0    0
+netstat=em0       - 10.0.2.0/24   10.0.2.15            51980     -     -   57682596    25333     -    2006926     -    -
+netstat=lo0   16384 <Link#2>      lo0                     48     0     0       3272       48     0       3272     0    0
+probe_end=1`
+
+func TestProbeMetrics(t *testing.T) {
+	type disk struct {
+		mount       string
+		used, total uint64
+		inodes      uint64
+	}
+	tests := []struct {
+		name      string
+		out       string
+		load      [3]float64
+		memUsed   uint64
+		memTotal  uint64
+		uptime    time.Duration
+		disks     []disk
+		net       []string
+		rxBytes   uint64 // of the first interface
+		temps     int
+		batteryAt float64 // 0 for no battery
+	}{
+		{
+			name:     "linux",
+			out:      linuxProbe,
+			load:     [3]float64{0.52, 0.48, 0.40},
+			memUsed:  2147483648,
+			memTotal: 8254296064,
+			uptime:   93784 * time.Second,
+			disks: []disk{
+				{"/", 98123556 * 1024, 490617784 * 1024, 31211520},
+				{"/boot/efi", 6220 * 1024, 523248 * 1024, 0},
+			},
+			net:       []string{"lo", "eth0"},
+			rxBytes:   1000,
+			temps:     2,
+			batteryAt: 81,
+		},
+		{
+			name:     "darwin",
+			out:      darwinProbe,
+			load:     [3]float64{1.52, 1.61, 1.70},
+			memUsed:  9663676416,
+			memTotal: 17179869184,
+			uptime:   5 * 24 * time.Hour,
+			disks: []disk{
+				{"/", 10107416 * 1024, 482797652 * 1024, 404167 + 2273940880},
+				{"/System/Volumes/Data", 240000000 * 1024, 482797652 * 1024, 2100000 + 2273940880},
+			},
+			net:       []string{"lo0", "en0"},
+			rxBytes:   4915820,
+			batteryAt: 64,
+		},
+		{
+			name:     "freebsd",
+			out:      freebsdProbe,
+			load:     [3]float64{0.10, 0.20, 0.30},
+			memUsed:  1073741824,
+			memTotal: 4253478912,
+			uptime:   time.Hour,
+			disks: []disk{
+				{"/", 4863540 * 1024, 19279260 * 1024, 214233 + 2389637},
+				{"/usr/home", 1000000 * 1024, 90000000 * 1024, 0},
+			},
+			net:     []string{"em0", "lo0"},
+			rxBytes: 58423180,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := parseProbe(probeOutput(tt.out))
+			if err != nil {
+				t.Fatalf("parseProbe: %v", err)
+			}
+			m := p.metrics()
+			if !m.HasLoad || [3]float64{m.Load1, m.Load5, m.Load15} != tt.load {
+				t.Errorf("load = %v %v %v (ok %v), want %v", m.Load1, m.Load5, m.Load15, m.HasLoad, tt.load)
+			}
+			if m.MemUsed != tt.memUsed || m.MemTotal != tt.memTotal {
+				t.Errorf("memory = %d/%d, want %d/%d", m.MemUsed, m.MemTotal, tt.memUsed, tt.memTotal)
+			}
+			if m.Uptime != tt.uptime {
+				t.Errorf("uptime = %v, want %v", m.Uptime, tt.uptime)
+			}
+			if len(m.Disks) != len(tt.disks) {
+				t.Fatalf("disks = %+v, want %d", m.Disks, len(tt.disks))
+			}
+			for i, want := range tt.disks {
+				d := m.Disks[i]
+				if d.Mount != want.mount || d.Used != want.used || d.Total != want.total || d.InodesTotal != want.inodes {
+					t.Errorf("disk %d = %s %d/%d inodes %d, want %s %d/%d inodes %d", i,
+						d.Mount, d.Used, d.Total, d.InodesTotal, want.mount, want.used, want.total, want.inodes)
+				}
+			}
+			var names []string
+			for _, ni := range m.Net {
+				names = append(names, ni.Name)
+			}
+			if !slices.Equal(names, tt.net) {
+				t.Errorf("interfaces = %v, want %v", names, tt.net)
+			} else if m.Net[0].RxBytes != tt.rxBytes {
+				t.Errorf("%s rx bytes = %d, want %d", names[0], m.Net[0].RxBytes, tt.rxBytes)
+			}
+			if len(m.Temps) != tt.temps {
+				t.Errorf("temps = %v, want %d", m.Temps, tt.temps)
+			}
+			switch {
+			case tt.batteryAt == 0 && m.Battery != nil:
+				t.Errorf("battery = %+v, want none", m.Battery)
+			case tt.batteryAt != 0 && (m.Battery == nil || m.Battery.Percent != tt.batteryAt):
+				t.Errorf("battery = %+v, want %v%%", m.Battery, tt.batteryAt)
+			}
+		})
+	}
+}
+
+func TestScriptStdin(t *testing.T) {
+	// A command reading stdin must not consume the rest of the script,
+	// even one longer than the shell reads ahead.
+	pad := strings.Repeat("echo pad=1\n", 10000)
+	out, err := runLocalScript(t.Context(), script("cat\nread x; echo x=$x\n", pad, "echo after=1\n"))
+	if err != nil {
+		t.Fatalf("runLocalScript: %v", err)
+	}
+	p, err := parseProbe(out)
+	if err != nil {
+		t.Fatalf("parseProbe: %v", err)
+	}
+	if p.get("after") != "1" || !p.complete() {
+		t.Errorf("script cut short: %q", out)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// tcpChecker reports a host as online if a TCP connection to host:port succeeds.
+type tcpChecker struct{}
+
+func (tcpChecker) Check(ctx context.Context, _ *monitor, hc HostConfig, status *HostStatus) {
+	start := time.Now()
+	d := net.Dialer{Timeout: hc.timeout()}
+	conn, err := d.DialContext(ctx, "tcp", hc.Address())
+	if err != nil {
+		status.fail(fmt.Errorf("dial: %w", err))
+		return
+	}
+	conn.Close()
+	status.Online = true
+	status.Latency = time.Since(start)
+	status.Detail = fmt.Sprintf("port %d open", hc.Port)
+}
+
+// httpChecker fetches hc.URL and checks the response status.
+type httpChecker struct{}
+
+func (httpChecker) Check(ctx context.Context, _ *monitor, hc HostConfig, status *HostStatus) {
+	client := &http.Client{
+		Timeout: hc.timeout(),
+		// Report redirects as-is rather than following them to another host.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, hc.URL, nil)
+	if err != nil {
+		status.fail(fmt.Errorf("http: %w", err))
+		return
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		status.fail(fmt.Errorf("http: %w", err))
+		return
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)) //nolint:errcheck
+	resp.Body.Close()
+	status.Latency = time.Since(start)
+	status.Detail = fmt.Sprintf("HTTP %d", resp.StatusCode)
+
+	ok := resp.StatusCode >= 200 && resp.StatusCode < 400
+	if hc.ExpectStatus != 0 {
+		ok = resp.StatusCode == hc.ExpectStatus
+	}
+	if !ok {
+		status.Error = fmt.Sprintf("unexpected status %s", resp.Status)
+		status.Failure = FailureCommand
+		return
+	}
+	status.Online = true
+}
+
+// dnsChecker resolves a name. With Query set, host is treated as the DNS
+// server to ask; otherwise host itself is resolved with the system resolver.
+type dnsChecker struct{}
+
+func (dnsChecker) Check(ctx context.Context, _ *monitor, hc HostConfig, status *HostStatus) {
+	resolver := net.DefaultResolver
+	name := hc.Host
+	if hc.Query != "" {
+		name = hc.Query
+		server := hc.Address()
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				d := net.Dialer{Timeout: hc.timeout()}
+				return d.DialContext(ctx, network, server)
+			},
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, hc.timeout())
+	defer cancel()
+	start := time.Now()
+	addrs, err := resolver.LookupHost(ctx, name)
+	if err != nil {
+		status.fail(fmt.Errorf("dns: %w", err))
+		return
+	}
+	status.Online = true
+	status.Latency = time.Since(start)
+	status.Detail = fmt.Sprintf("%s → %s", name, strings.Join(addrs, ", "))
+}
+
+// tlsChecker completes a TLS handshake and reports certificate expiry.
+type tlsChecker struct{}
+
+func (tlsChecker) Check(ctx context.Context, _ *monitor, hc HostConfig, status *HostStatus) {
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: hc.timeout()},
+		Config:    &tls.Config{ServerName: hc.Host},
+	}
+	start := time.Now()
+	c, err := dialer.DialContext(ctx, "tcp", hc.Address())
+	if err != nil {
+		status.fail(fmt.Errorf("tls: %w", err))
+		return
+	}
+	conn := c.(*tls.Conn)
+	defer conn.Close()
+	status.Latency = time.Since(start)
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		status.Error = "tls: no peer certificate"
+		status.Failure = FailureAuth
+		return
+	}
+	left := time.Until(certs[0].NotAfter)
+	status.Detail = fmt.Sprintf("cert expires in %dd", int(left.Hours()/24))
+	status.Online = true
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Process is one entry of a top-processes snapshot.
+type Process struct {
+	PID     int     `json:"pid"`
+	Command string  `json:"command"`
+	CPU     float64 `json:"cpu_percent"`
+	RSS     uint64  `json:"rss_bytes"`
+}
+
+// processProbe lists the top n processes by CPU and by resident memory.
+// GNU ps sorts with --sort; BSD/macOS ps uses -r (CPU) and -m (memory).
+func processProbe(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(`if ps -eo pid= --sort=-%%cpu >/dev/null 2>&1; then
+	ps -eo pid=,%%cpu=,rss=,comm= --sort=-%%cpu | head -n %[1]d | sed 's/^/top_cpu=/'
+	ps -eo pid=,%%cpu=,rss=,comm= --sort=-rss | head -n %[1]d | sed 's/^/top_rss=/'
+else
+	ps -Aro pid=,%%cpu=,rss=,comm= | head -n %[1]d | sed 's/^/top_cpu=/'
+	ps -Amo pid=,%%cpu=,rss=,comm= | head -n %[1]d | sed 's/^/top_rss=/'
+fi
+`, n)
+}
+
+// parseProcesses parses "pid %cpu rss-KiB command" lines. The command
+// comes last because on macOS it is a path that may contain spaces.
+func parseProcesses(lines []string) []Process {
+	var out []Process
+	for _, line := range lines {
+		f := strings.Fields(line)
+		if len(f) < 4 {
+			continue
+		}
+		pid, err := strconv.Atoi(f[0])
+		if err != nil {
+			continue
+		}
+		cpu, _ := strconv.ParseFloat(f[1], 64)
+		rss, _ := strconv.ParseUint(f[2], 10, 64)
+		out = append(out, Process{
+			PID:     pid,
+			CPU:     cpu,
+			RSS:     rss * 1024,
+			Command: strings.Join(f[3:], " "),
+		})
+	}
+	return out
+}
+
+func (p Process) String() string {
+	return fmt.Sprintf("%6d %-16s %5.1f%% %s", p.PID, truncate(p.Command, 16), p.CPU, formatBytes(p.RSS))
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// defaultConcurrency is how many hosts are checked at once unless
+// Config.Concurrency says otherwise.
+const defaultConcurrency = 16
+
+// concurrency returns how many hosts may be worked on at once.
+func (c *Config) concurrency() int {
+	if c.Concurrency <= 0 {
+		return defaultConcurrency
+	}
+	return c.Concurrency
+}
+
+// scheduler bounds how many checks run at once and makes sure a host is
+// never checked twice at the same time, even when a new round starts while
+// an earlier, cancelled one is still winding down.
+type scheduler struct {
+	mu   sync.Mutex
+	sem  chan struct{}
+	busy map[string]chan struct{} // host name -> closed when its check ends
+}
+
+func newScheduler() *scheduler {
+	return &scheduler{busy: make(map[string]chan struct{})}
+}
+
+// slots returns the concurrency semaphore, sized on first use.
+func (s *scheduler) slots(limit int) chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sem == nil {
+		s.sem = make(chan struct{}, limit)
+	}
+	return s.sem
+}
+
+// check runs run for hc once any earlier check of the same host has
+// finished and a slot is free. It reports false if ctx ended first or
+// during the check, in which case the result should be discarded.
+func (s *scheduler) check(ctx context.Context, hc HostConfig, limit int, run func(context.Context, HostConfig) HostStatus) (HostStatus, bool) {
+	var done chan struct{}
+	for {
+		s.mu.Lock()
+		prev, busy := s.busy[hc.Name]
+		if !busy {
+			done = make(chan struct{})
+			s.busy[hc.Name] = done
+			s.mu.Unlock()
+			break
+		}
+		s.mu.Unlock()
+		select {
+		case <-prev:
+		case <-ctx.Done():
+			return HostStatus{}, false
+		}
+	}
+	defer func() {
+		s.mu.Lock()
+		delete(s.busy, hc.Name)
+		s.mu.Unlock()
+		close(done)
+	}()
+
+	sem := s.slots(limit)
+	select {
+	case sem <- struct{}{}:
+	case <-ctx.Done():
+		return HostStatus{}, false
+	}
+	defer func() { <-sem }()
+
+	status := run(ctx, hc)
+	return status, ctx.Err() == nil
+}
+
+// checkAllHosts checks every host through the scheduler and returns once
+// all are done or ctx is cancelled. Hosts not checked before cancellation
+// keep a zero LastCheck.
+func (mon *monitor) checkAllHosts(ctx context.Context, cfg *Config) []HostStatus {
+	results := make([]HostStatus, len(cfg.Hosts))
+	var wg sync.WaitGroup
+	for i, hc := range cfg.Hosts {
+		results[i] = HostStatus{Config: hc}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if status, ok := mon.scheduler.check(ctx, hc, cfg.concurrency(), mon.checkHost); ok {
+				results[i] = status
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// watchHosts checks every host on its own timer until ctx is cancelled,
+// sending each result to out as soon as it is ready. All hosts are checked
+// right away; the second check comes somewhere in the second half of the
+// interval and later ones after the interval ±10%, so hosts sharing an
+// interval spread out instead of firing together. Hosts in trouble use the
+// shorter adaptiveInterval instead.
+func (mon *monitor) watchHosts(ctx context.Context, cfg *Config, out chan<- HostStatus) {
+	var wg sync.WaitGroup
+	for _, hc := range cfg.Hosts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var prevState string
+			var fast time.Duration
+			for first := true; ; first = false {
+				status, ok := mon.scheduler.check(ctx, hc, cfg.concurrency(), mon.checkHost)
+				if !ok {
+					return
+				}
+				fast = adaptiveInterval(hc, status, prevState, fast)
+				prevState = status.State()
+				status.Interval = hc.interval()
+				if fast > 0 {
+					status.Interval = fast
+				}
+				select {
+				case out <- status:
+				case <-ctx.Done():
+					return
+				}
+
+				wait := jitter(status.Interval)
+				if first && fast == 0 {
+					wait = status.Interval/2 + rand.N(status.Interval/2)
+				}
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// adaptiveInterval returns the shortened interval for a host in trouble,
+// or 0 when it should go back to its normal interval. A host is in trouble
+// when it is down or degraded, or its state just changed and needs
+// confirming; it is rechecked after MinInterval, doubling on each further
+// troubled check up to MaxInterval. last is the previous return value.
+func adaptiveInterval(hc HostConfig, s HostStatus, prevState string, last time.Duration) time.Duration {
+	state := s.State()
+	if state == string(HealthOK) && (prevState == "" || prevState == state) {
+		return 0
+	}
+	if last == 0 {
+		return time.Duration(hc.MinInterval) * time.Second
+	}
+	return min(last*2, time.Duration(hc.MaxInterval)*time.Second)
+}
+
+// jitter returns d shifted randomly by up to ±10%.
+func jitter(d time.Duration) time.Duration {
+	return d - d/10 + rand.N(d/5+1)
+}
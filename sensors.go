@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Temperature is one temperature sensor reading.
+type Temperature struct {
+	Name    string  `json:"name"`
+	Celsius float64 `json:"celsius"`
+}
+
+// Battery is the state of the host's main battery.
+type Battery struct {
+	Percent float64 `json:"percent"`
+	Status  string  `json:"status"` // charging, discharging, full, ...
+	OnAC    bool    `json:"on_ac"`
+}
+
+// Raspberry Pi `vcgencmd get_throttled` bits. The same flags shifted left
+// by 16 mean the condition has occurred since boot.
+var throttleFlags = []struct {
+	bit  uint32
+	name string
+}{
+	{1 << 0, "under-voltage"},
+	{1 << 1, "frequency capped"},
+	{1 << 2, "throttled"},
+	{1 << 3, "soft temperature limit"},
+}
+
+// sensorProbe reads thermal zones, hwmon chips, the Pi firmware throttle
+// state and battery/AC status (sysfs on Linux, pmset on macOS).
+const sensorProbe = `
+for z in /sys/class/thermal/thermal_zone*; do
+	[ -r "$z/temp" ] && echo "zone=$(cat "$z/type") $(cat "$z/temp")"
+done
+for t in /sys/class/hwmon/hwmon*/temp*_input; do
+	[ -r "$t" ] || continue
+	l=$(cat "${t%_input}_label") || { l=${t##*/}; l=${l%_input}; }
+	echo "hwmon=$(cat "${t%/*}/name")/$l $(cat "$t")"
+done
+for p in /sys/class/power_supply/*; do
+	case $(cat "$p/type") in
+	Battery) echo "battery=$(cat "$p/capacity") $(cat "$p/status")" ;;
+	Mains|USB) [ "$(cat "$p/online")" = 1 ] && echo "ac=1" ;;
+	esac
+done
+command -v pmset >/dev/null && pmset -g batt | sed 's/^/pmset=/'
+` + throttleProbe
+
+// throttleProbe asks the Pi firmware for its throttle flags.
+const throttleProbe = `command -v vcgencmd >/dev/null && echo "throttled=$(vcgencmd get_throttled | cut -d= -f2)"
+`
+
+// parseTemps reads "name millidegrees" lines from thermal zones and hwmon.
+// hwmon chips that merely mirror a thermal zone (cpu_thermal for
+// cpu-thermal) are skipped, as are implausible values.
+func parseTemps(zones, hwmon []string) []Temperature {
+	var out []Temperature
+	zoneNames := make(map[string]bool)
+	add := func(line string) (string, bool) {
+		i := strings.LastIndexByte(line, ' ')
+		if i < 0 {
+			return "", false
+		}
+		milli, err := strconv.ParseFloat(line[i+1:], 64)
+		c := milli / 1000
+		if err != nil || c <= 0 || c > 150 {
+			return "", false
+		}
+		name := strings.TrimSpace(line[:i])
+		out = append(out, Temperature{Name: name, Celsius: c})
+		return name, true
+	}
+	for _, line := range zones {
+		if name, ok := add(line); ok {
+			zoneNames[strings.ReplaceAll(name, "-", "_")] = true
+		}
+	}
+	for _, line := range hwmon {
+		chip, _, _ := strings.Cut(line, "/")
+		if !zoneNames[chip] {
+			add(line)
+		}
+	}
+	return out
+}
+
+// parseThrottled parses the hex value printed by vcgencmd, e.g. "0x50005".
+func parseThrottled(s string) (uint32, bool) {
+	v, err := strconv.ParseUint(strings.TrimPrefix(s, "0x"), 16, 32)
+	return uint32(v), err == nil
+}
+
+// throttleString describes active throttle flags, then those seen since boot.
+func throttleString(v uint32) string {
+	var now, past []string
+	for _, f := range throttleFlags {
+		if v&f.bit != 0 {
+			now = append(now, f.name)
+		} else if v&(f.bit<<16) != 0 {
+			past = append(past, f.name)
+		}
+	}
+	s := strings.Join(now, ", ")
+	if len(past) > 0 {
+		if s != "" {
+			s += "; "
+		}
+		s += "earlier: " + strings.Join(past, ", ")
+	}
+	return s
+}
+
+// parseBattery reads the sysfs battery/ac lines, or pmset -g batt output:
+//
+//	Now drawing from 'AC Power'
+//	 -InternalBattery-0 (id=1234)	85%; charging; 1:02 remaining present: true
+func parseBattery(p probeResult) *Battery {
+	if v := p.get("battery"); v != "" {
+		pct, status, _ := strings.Cut(v, " ")
+		n, err := strconv.ParseFloat(pct, 64)
+		if err != nil {
+			return nil
+		}
+		return &Battery{Percent: n, Status: strings.ToLower(status), OnAC: p.get("ac") == "1"}
+	}
+	var b *Battery
+	onAC := false
+	for _, line := range p["pmset"] {
+		if strings.Contains(line, "'AC Power'") {
+			onAC = true
+		}
+		_, rest, ok := strings.Cut(line, "\t")
+		if !ok || !strings.Contains(rest, "%;") {
+			continue
+		}
+		f := strings.Split(rest, ";")
+		n, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(f[0]), "%"), 64)
+		if err != nil {
+			continue
+		}
+		b = &Battery{Percent: n}
+		if len(f) > 1 {
+			b.Status = strings.TrimSpace(f[1])
+		}
+	}
+	if b != nil {
+		b.OnAC = onAC
+	}
+	return b
+}
+
+// MaxTemp returns the hottest sensor reading, if any.
+func (m *Metrics) MaxTemp() (Temperature, bool) {
+	if m == nil || len(m.Temps) == 0 {
+		return Temperature{}, false
+	}
+	hot := m.Temps[0]
+	for _, t := range m.Temps[1:] {
+		if t.Celsius > hot.Celsius {
+			hot = t
+		}
+	}
+	return hot, true
+}
+
+func (m *Metrics) TempString() string {
+	if t, ok := m.MaxTemp(); ok {
+		return fmt.Sprintf("%.0f°C", t.Celsius)
+	}
+	return ""
+}
+
+func (b *Battery) String() string {
+	s := fmt.Sprintf("%.0f%%", b.Percent)
+	if b.Status != "" {
+		s += " " + b.Status
+	}
+	if b.OnAC {
+		s += ", on AC"
+	}
+	return s
+}
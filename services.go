@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ServiceStatus is the state of one watched systemd unit.
+type ServiceStatus struct {
+	Name        string `json:"name"`
+	LoadState   string `json:"load_state"`
+	ActiveState string `json:"active_state"`
+	SubState    string `json:"sub_state"`
+	Restarts    int    `json:"restarts"`
+	Health      Health `json:"health"`
+}
+
+// serviceProbe emits systemctl show output for each unit plus the number
+// (and names) of failed units on the host. Hosts without systemd print nothing.
+func serviceProbe(units []string) string {
+	var b strings.Builder
+	b.WriteString("if command -v systemctl >/dev/null; then\n")
+	b.WriteString("\techo \"failed_units=$(systemctl list-units --state=failed --no-legend --plain | wc -l)\"\n")
+	b.WriteString("\tsystemctl list-units --state=failed --no-legend --plain | awk 'NR<=20{print \"failed_unit=\" $1}'\n")
+	for _, u := range units {
+		fmt.Fprintf(&b, "\tprintf 'service=%%s %%s\\n' %s \"$(systemctl show %s -p LoadState,ActiveState,SubState,NRestarts | tr '\\n' ' ')\"\n", shellQuote(u), shellQuote(u))
+	}
+	b.WriteString("fi\n")
+	return b.String()
+}
+
+// services parses service= lines, e.g.
+// "nginx LoadState=loaded ActiveState=active SubState=running NRestarts=0".
+func (p probeResult) services() []ServiceStatus {
+	var out []ServiceStatus
+	for _, line := range p["service"] {
+		f := strings.Fields(line)
+		if len(f) == 0 {
+			continue
+		}
+		s := ServiceStatus{Name: f[0]}
+		for _, kv := range f[1:] {
+			k, v, _ := strings.Cut(kv, "=")
+			switch k {
+			case "LoadState":
+				s.LoadState = v
+			case "ActiveState":
+				s.ActiveState = v
+			case "SubState":
+				s.SubState = v
+			case "NRestarts":
+				s.Restarts, _ = strconv.Atoi(v)
+			}
+		}
+		s.Health = s.health()
+		out = append(out, s)
+	}
+	return out
+}
+
+func (s ServiceStatus) health() Health {
+	switch {
+	case s.LoadState == "not-found":
+		return HealthCritical
+	case s.ActiveState == "active":
+		return HealthOK
+	case s.ActiveState == "activating" || s.ActiveState == "reloading":
+		return HealthWarning
+	case s.ActiveState == "":
+		return HealthUnknown
+	default:
+		return HealthCritical
+	}
+}
+
+// describe renders the unit state for display, e.g. "failed (failed)".
+func (s ServiceStatus) describe() string {
+	if s.LoadState == "not-found" {
+		return "not found"
+	}
+	d := s.ActiveState
+	if s.SubState != "" && s.SubState != s.ActiveState {
+		d += " (" + s.SubState + ")"
+	}
+	if s.Restarts > 0 {
+		d += fmt.Sprintf(", %d restarts", s.Restarts)
+	}
+	return d
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSystemctl puts a systemctl on PATH that reports every unit as active
+// except "gone.service", which is not found.
+func fakeSystemctl(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	script := `#!/bin/sh
+[ "$1" = show ] || exit 0
+if [ "$2" = gone.service ]; then
+	printf 'LoadState=not-found\nActiveState=inactive\nSubState=dead\nNRestarts=0\n'
+else
+	printf 'LoadState=loaded\nActiveState=active\nSubState=running\nNRestarts=2\n'
+fi
+`
+	if err := os.WriteFile(filepath.Join(dir, "systemctl"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestServiceProbeQuotesUnits(t *testing.T) {
+	fakeSystemctl(t)
+	// Each would print a different name if the shell expanded it.
+	units := []string{
+		"nginx.service",
+		`odd"name\.service`,
+		"x$(echo,pwned).service",
+		"y`echo,pwned`$HOME.service",
+		"gone.service",
+	}
+	out, err := runLocalScript(t.Context(), script(serviceProbe(units)))
+	if err != nil {
+		t.Fatalf("runLocalScript: %v", err)
+	}
+	p, err := parseProbe(out)
+	if err != nil {
+		t.Fatalf("parseProbe: %v", err)
+	}
+	got := p.services()
+	if len(got) != len(units) {
+		t.Fatalf("services = %+v, want %d", got, len(units))
+	}
+	for i, s := range got {
+		if s.Name != units[i] {
+			t.Errorf("service %d name = %q, want %q", i, s.Name, units[i])
+		}
+	}
+	if s := got[0]; s.Health != HealthOK || s.SubState != "running" || s.Restarts != 2 {
+		t.Errorf("nginx = %+v", s)
+	}
+	if s := got[4]; s.Health != HealthCritical || s.describe() != "not found" {
+		t.Errorf("gone = %+v", s)
+	}
+}
+
+func TestServiceReasons(t *testing.T) {
+	s := HostStatus{Online: true, LastCheck: time.Now(), Config: HostConfig{Thresholds: defaultThresholds}}
+	s.Services = []ServiceStatus{
+		{Name: "ssh", Health: HealthUnknown},
+		{Name: "nginx", ActiveState: "active", SubState: "running", Health: HealthOK},
+		{Name: "db", ActiveState: "activating", SubState: "start", Health: HealthWarning},
+	}
+	evaluateHealth(&s)
+	if s.Health != HealthWarning {
+		t.Errorf("health = %s, want warning", s.Health)
+	}
+	if got := strings.Join(s.Reasons, "; "); got != "service db activating (start)" {
+		t.Errorf("reasons = %q", got)
+	}
+}
+
+func TestComponentNotifyIsQuoted(t *testing.T) {
+	dir := t.TempDir()
+	pwn := "; touch " + dir + "/PWNED; echo "
+	unit := "x'" + pwn + "'.service"
+	message := "not a number: \"'" + pwn + "'$(touch " + dir + "/PWNED)\""
+	hc := HostConfig{Name: "nas", Label: "nas", Host: "10.0.0.3"}
+	st := NewStateTracker(NotifyConfig{Command: "echo '{component}' {state} \"{reason}\" >> " + dir + "/out"})
+	status := func(svc, check Health) HostStatus {
+		return HostStatus{Config: hc, Online: true, Health: HealthOK, LastCheck: time.Now(),
+			Services: []ServiceStatus{{Name: unit, ActiveState: "failed", SubState: "failed", Health: svc}},
+			Checks:   []CheckResult{{Name: "queue", Health: check, Message: message}},
+		}
+	}
+	st.Update([]HostStatus{status(HealthOK, HealthOK)})
+	st.Update([]HostStatus{status(HealthCritical, HealthOK)})
+	first := waitForFile(t, filepath.Join(dir, "out"))
+	st.Update([]HostStatus{status(HealthCritical, HealthWarning)})
+	var out []byte
+	for range 100 {
+		if out, _ = os.ReadFile(filepath.Join(dir, "out")); len(out) > len(first) {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "PWNED")); err == nil {
+		t.Fatal("a component name or detail ran as shell")
+	}
+	want := "service " + unit + " critical failed\ncheck queue warning " + message + "\n"
+	if string(out) != want {
+		t.Errorf("command wrote %q, want %q", out, want)
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// sshConfigEntry holds the ~/.ssh/config settings pulse understands for one alias.
+type sshConfigEntry struct {
+	HostName      string
+	User          string
+	Port          int
+	IdentityFiles []string
+	ProxyJump     string
+}
+
+func defaultSSHConfigPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".ssh", "config")
+}
+
+// lookupSSHConfig resolves alias against an OpenSSH client config file,
+// following Include directives. As with ssh(1), the first value obtained for
+// each keyword wins; Match blocks are not supported and are skipped.
+func lookupSSHConfig(file, alias string) (sshConfigEntry, error) {
+	var e sshConfigEntry
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		return e, nil
+	}
+	if err := readSSHConfig(file, alias, &e, true, 0); err != nil {
+		return e, err
+	}
+	e.HostName = strings.ReplaceAll(e.HostName, "%h", alias)
+	return e, nil
+}
+
+func readSSHConfig(file, alias string, e *sshConfigEntry, active bool, depth int) error {
+	if depth > 16 {
+		return fmt.Errorf("ssh config: include nested too deeply at %s", file)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("ssh config: %w", err)
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		key, args := splitSSHConfigLine(sc.Text())
+		if key == "" || len(args) == 0 {
+			continue
+		}
+		switch key {
+		case "host":
+			active = matchHostPatterns(alias, args)
+			continue
+		case "match":
+			active = len(args) == 1 && strings.EqualFold(args[0], "all")
+			continue
+		}
+		if !active {
+			continue
+		}
+		switch key {
+		case "include":
+			for _, pattern := range args {
+				pattern = expandHome(pattern)
+				if !filepath.IsAbs(pattern) {
+					pattern = filepath.Join(filepath.Dir(defaultSSHConfigPath()), pattern)
+				}
+				matches, _ := filepath.Glob(pattern)
+				for _, m := range matches {
+					if err := readSSHConfig(m, alias, e, true, depth+1); err != nil {
+						return err
+					}
+				}
+			}
+		case "hostname":
+			if e.HostName == "" {
+				e.HostName = args[0]
+			}
+		case "user":
+			if e.User == "" {
+				e.User = args[0]
+			}
+		case "port":
+			if e.Port == 0 {
+				e.Port, _ = strconv.Atoi(args[0])
+			}
+		case "identityfile":
+			e.IdentityFiles = append(e.IdentityFiles, args[0])
+		case "proxyjump":
+			if e.ProxyJump == "" {
+				e.ProxyJump = args[0]
+			}
+		}
+	}
+	return sc.Err()
+}
+
+// splitSSHConfigLine returns the lowercased keyword and its arguments,
+// honouring "Key=value" syntax, double quotes and trailing comments.
+func splitSSHConfigLine(line string) (string, []string) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", nil
+	}
+	end := strings.IndexAny(line, " \t=")
+	if end < 0 {
+		return strings.ToLower(line), nil
+	}
+	key := strings.ToLower(line[:end])
+	rest := strings.TrimLeft(line[end:], " \t")
+	rest = strings.TrimLeft(strings.TrimPrefix(rest, "="), " \t")
+
+	var args []string
+	var cur strings.Builder
+	inQuote, have := false, false
+	for _, r := range rest {
+		if !inQuote && r == '#' {
+			break
+		}
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			have = true
+		case !inQuote && (r == ' ' || r == '\t'):
+			if have {
+				args = append(args, cur.String())
+				cur.Reset()
+				have = false
+			}
+		default:
+			cur.WriteRune(r)
+			have = true
+		}
+	}
+	if have {
+		args = append(args, cur.String())
+	}
+	return key, args
+}
+
+// matchHostPatterns implements ssh_config Host matching: any positive
+// pattern must match and no negated (!) pattern may match.
+func matchHostPatterns(alias string, patterns []string) bool {
+	matched := false
+	for _, p := range patterns {
+		negate := strings.HasPrefix(p, "!")
+		p = strings.TrimPrefix(p, "!")
+		ok, _ := path.Match(strings.ToLower(p), strings.ToLower(alias))
+		if ok && negate {
+			return false
+		}
+		if ok {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// applySSHConfig fills in unset fields of an ssh host from its ~/.ssh/config
+// entry, including any ProxyJump chain. Explicit hosts.yaml values win.
+func applySSHConfig(hc *HostConfig, file string, depth int) error {
+	if depth > 8 {
+		return fmt.Errorf("ProxyJump chain too long at %s", hc.Host)
+	}
+	e, err := lookupSSHConfig(file, hc.Host)
+	if err != nil {
+		return err
+	}
+	if e.HostName != "" {
+		hc.Host = e.HostName
+	}
+	if hc.User == "" {
+		hc.User = e.User
+	}
+	if hc.User == "" {
+		if u, err := user.Current(); err == nil {
+			hc.User = u.Username
+		}
+	}
+	if hc.Port == 0 {
+		hc.Port = e.Port
+	}
+	if hc.Port == 0 {
+		hc.Port = 22
+	}
+	hc.identityFiles = e.IdentityFiles
+
+	if e.ProxyJump != "" && !strings.EqualFold(e.ProxyJump, "none") {
+		for _, spec := range strings.Split(e.ProxyJump, ",") {
+			hop := parseJumpSpec(strings.TrimSpace(spec))
+			if err := applySSHConfig(&hop, file, depth+1); err != nil {
+				return err
+			}
+			hc.jumps = append(hc.jumps, hop.jumps...)
+			hop.jumps = nil
+			hc.jumps = append(hc.jumps, hop)
+		}
+	}
+	return nil
+}
+
+// parseJumpSpec parses a [user@]host[:port] jump host specification.
+func parseJumpSpec(spec string) HostConfig {
+	hc := HostConfig{Type: "ssh", Name: spec, Label: spec}
+	if i := strings.LastIndex(spec, "@"); i >= 0 {
+		hc.User, spec = spec[:i], spec[i+1:]
+	}
+	if host, port, err := net.SplitHostPort(spec); err == nil {
+		hc.Host = host
+		hc.Port, _ = strconv.Atoi(port)
+	} else {
+		hc.Host = spec
+	}
+	return hc
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSplitSSHConfigLine(t *testing.T) {
+	tests := []struct {
+		line string
+		key  string
+		args []string
+	}{
+		{"", "", nil},
+		{"   # just a comment", "", nil},
+		{"HostName example.com", "hostname", []string{"example.com"}},
+		{"\tUser\tbob  ", "user", []string{"bob"}},
+		{"Port=2222", "port", []string{"2222"}},
+		{"Port = 2222", "port", []string{"2222"}},
+		{"Port =2222", "port", []string{"2222"}},
+		{`IdentityFile "~/.ssh/my key"`, "identityfile", []string{"~/.ssh/my key"}},
+		{`IdentityFile ""`, "identityfile", []string{""}},
+		{"Host web db !db-old # the app servers", "host", []string{"web", "db", "!db-old"}},
+		{`User "bob # not a comment"`, "user", []string{"bob # not a comment"}},
+		{"Compression", "compression", nil},
+	}
+	for _, tt := range tests {
+		key, args := splitSSHConfigLine(tt.line)
+		if key != tt.key || !slices.Equal(args, tt.args) {
+			t.Errorf("splitSSHConfigLine(%q) = %q %q, want %q %q", tt.line, key, args, tt.key, tt.args)
+		}
+	}
+}
+
+func TestMatchHostPatterns(t *testing.T) {
+	tests := []struct {
+		alias    string
+		patterns []string
+		want     bool
+	}{
+		{"web", []string{"web"}, true},
+		{"WEB", []string{"web"}, true},
+		{"web1", []string{"web?"}, true},
+		{"db.lan", []string{"*.lan"}, true},
+		{"db.lan", []string{"*.lan", "!db.*"}, false},
+		{"web.lan", []string{"*.lan", "!db.*"}, true},
+		{"web", []string{"!db"}, false}, // negation alone never matches
+		{"web", []string{"db", "cache"}, false},
+	}
+	for _, tt := range tests {
+		if got := matchHostPatterns(tt.alias, tt.patterns); got != tt.want {
+			t.Errorf("matchHostPatterns(%q, %q) = %v, want %v", tt.alias, tt.patterns, got, tt.want)
+		}
+	}
+}
+
+// writeSSHConfig writes files under a fresh $HOME/.ssh and returns the path
+// of its config.
+func writeSSHConfig(t *testing.T, files map[string]string) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := filepath.Join(home, ".ssh")
+	for name, body := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filepath.Join(dir, "config")
+}
+
+func TestLookupSSHConfig(t *testing.T) {
+	file := writeSSHConfig(t, map[string]string{
+		"config": `
+# first value wins, so specific hosts go first
+Include conf.d/*.conf
+
+Host nas
+    HostName 192.168.1.20
+    User admin
+    IdentityFile ~/.ssh/nas_key
+
+Host web?.lan !web9.lan
+    HostName %h.example.com
+    Port=2201
+
+Host pi
+    Include pi.conf
+
+Match exec "false"
+    User nobody
+
+Host *
+    User fallback
+    Port 22
+    IdentityFile ~/.ssh/id_ed25519
+`,
+		"conf.d/10-db.conf": `
+Host db
+    HostName "10.0.0.5"
+    Port 5022
+# a Host line here ends the block only inside this file
+Host other
+    User other
+`,
+		"pi.conf": `
+User pi
+Port 2222
+`,
+	})
+	tests := []struct {
+		alias string
+		want  sshConfigEntry
+	}{
+		{"nas", sshConfigEntry{HostName: "192.168.1.20", User: "admin", Port: 22,
+			IdentityFiles: []string{"~/.ssh/nas_key", "~/.ssh/id_ed25519"}}},
+		{"web1.lan", sshConfigEntry{HostName: "web1.lan.example.com", User: "fallback", Port: 2201,
+			IdentityFiles: []string{"~/.ssh/id_ed25519"}}},
+		{"web9.lan", sshConfigEntry{User: "fallback", Port: 22, IdentityFiles: []string{"~/.ssh/id_ed25519"}}},
+		{"db", sshConfigEntry{HostName: "10.0.0.5", User: "fallback", Port: 5022, IdentityFiles: []string{"~/.ssh/id_ed25519"}}},
+		{"pi", sshConfigEntry{User: "pi", Port: 2222, IdentityFiles: []string{"~/.ssh/id_ed25519"}}},
+		{"unknown", sshConfigEntry{User: "fallback", Port: 22, IdentityFiles: []string{"~/.ssh/id_ed25519"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alias, func(t *testing.T) {
+			got, err := lookupSSHConfig(file, tt.alias)
+			if err != nil {
+				t.Fatalf("lookupSSHConfig: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lookupSSHConfig(%q) =\n%+v\nwant\n%+v", tt.alias, got, tt.want)
+			}
+		})
+	}
+
+	if e, err := lookupSSHConfig(filepath.Join(t.TempDir(), "missing"), "nas"); err != nil || !reflect.DeepEqual(e, sshConfigEntry{}) {
+		t.Errorf("missing config = %+v, %v; want nothing", e, err)
+	}
+}
+
+func TestLookupSSHConfigIncludeLoop(t *testing.T) {
+	file := writeSSHConfig(t, map[string]string{"config": "Include config\n"})
+	if _, err := lookupSSHConfig(file, "any"); err == nil || !strings.Contains(err.Error(), "nested too deeply") {
+		t.Errorf("self-including config: %v, want a nesting error", err)
+	}
+}
+
+func TestApplySSHConfigProxyJump(t *testing.T) {
+	file := writeSSHConfig(t, map[string]string{"config": `
+Host app
+    HostName 10.1.0.7
+    ProxyJump bastion,ops@inner:2200
+
+Host bastion
+    HostName bastion.example.com
+    User jump
+    ProxyJump gateway
+
+Host gateway
+    HostName 203.0.113.9
+    Port 443
+    User gw
+
+Host direct
+    HostName 10.1.0.8
+    ProxyJump none
+
+Host loop
+    ProxyJump loop
+
+Host *
+    User me
+`})
+	hop := func(h HostConfig) string {
+		return h.User + "@" + h.Host + ":" + strconv.Itoa(h.Port)
+	}
+	tests := []struct {
+		host  string
+		want  string
+		jumps []string
+	}{
+		{"app", "me@10.1.0.7:22", []string{"gw@203.0.113.9:443", "jump@bastion.example.com:22", "ops@inner:2200"}},
+		{"direct", "me@10.1.0.8:22", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			hc := HostConfig{Type: "ssh", Host: tt.host}
+			if err := applySSHConfig(&hc, file, 0); err != nil {
+				t.Fatalf("applySSHConfig: %v", err)
+			}
+			var jumps []string
+			for _, j := range hc.jumps {
+				jumps = append(jumps, hop(j))
+			}
+			if hop(hc) != tt.want || !slices.Equal(jumps, tt.jumps) {
+				t.Errorf("%s = %s via %v, want %s via %v", tt.host, hop(hc), jumps, tt.want, tt.jumps)
+			}
+		})
+	}
+
+	t.Run("explicit values win", func(t *testing.T) {
+		hc := HostConfig{Type: "ssh", Host: "gateway", User: "root", Port: 2022}
+		if err := applySSHConfig(&hc, file, 0); err != nil {
+			t.Fatal(err)
+		}
+		if got := hop(hc); got != "root@203.0.113.9:2022" {
+			t.Errorf("gateway = %s, want hosts.yaml user and port kept", got)
+		}
+	})
+
+	t.Run("loop", func(t *testing.T) {
+		hc := HostConfig{Type: "ssh", Host: "loop"}
+		if err := applySSHConfig(&hc, file, 0); err == nil || !strings.Contains(err.Error(), "too long") {
+			t.Errorf("ProxyJump loop: %v, want an error", err)
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -23,6 +24,12 @@ var (
 	offlineStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("196"))
 
+	warnStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("226"))
+
+	flapStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("208"))
+
 	labelStyle = lipgloss.NewStyle().
 			Bold(true).
 			Foreground(lipgloss.Color("15"))
@@ -57,15 +64,25 @@ type model struct {
 	once     bool
 	tab      viewTab
 	jira     jiraModel
+	tracker  *StateTracker
+	mon      *monitor
+
+	gen     int                // current watch; results from older ones are ignored
+	cancel  context.CancelFunc // stops the current watch
+	results chan HostStatus    // results of the current watch
+	fresh   map[string]bool    // hosts reported since the watch started
 }
 
-type checkDoneMsg struct {
-	results []HostStatus
+// hostResultMsg carries one finished check from the watch gen.
+type hostResultMsg struct {
+	gen    int
+	status HostStatus
 }
 
-type tickMsg time.Time
+// startMsg starts the first watch once the program is running.
+type startMsg struct{}
 
-func initialModel(cfg *Config, once bool, jm jiraModel) model {
+func initialModel(cfg *Config, once bool, jm jiraModel, mon *monitor) model {
 	s := spinner.New()
 	s.Spinner = spinner.Dot
 	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("62"))
@@ -81,13 +98,15 @@ func initialModel(cfg *Config, once bool, jm jiraModel) model {
 		spinner: s,
 		once:    once,
 		jira:    jm,
+		tracker: NewStateTracker(cfg.Notify),
+		mon:     mon,
 	}
 }
 
 func (m model) Init() tea.Cmd {
 	cmds := []tea.Cmd{
 		m.spinner.Tick,
-		m.runChecks(),
+		func() tea.Msg { return startMsg{} },
 	}
 	if jiraCmd := m.jira.Init(); jiraCmd != nil {
 		cmds = append(cmds, jiraCmd)
@@ -95,9 +114,33 @@ func (m model) Init() tea.Cmd {
 	return tea.Batch(cmds...)
 }
 
-func (m model) runChecks() tea.Cmd {
+// startWatch cancels the running watch, including checks in flight, and
+// starts a new one that checks every host right away.
+func (m *model) startWatch() tea.Cmd {
+	if m.cancel != nil {
+		m.cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.gen++
+	m.checking = true
+	m.fresh = make(map[string]bool)
+	m.results = make(chan HostStatus, len(m.config.Hosts))
+	go func(ch chan HostStatus) {
+		m.mon.watchHosts(ctx, m.config, ch)
+		close(ch)
+	}(m.results)
+	return waitForResult(m.gen, m.results)
+}
+
+// waitForResult delivers the next result of a watch.
+func waitForResult(gen int, ch <-chan HostStatus) tea.Cmd {
 	return func() tea.Msg {
-		return checkDoneMsg{results: checkAllHosts(m.config)}
+		status, ok := <-ch
+		if !ok {
+			return nil
+		}
+		return hostResultMsg{gen: gen, status: status}
 	}
 }
 
@@ -106,6 +149,9 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "q", "ctrl+c":
+			if m.cancel != nil {
+				m.cancel()
+			}
 			return m, tea.Quit
 		case "tab":
 			if m.tab == tabHosts {
@@ -136,23 +182,32 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.cursor++
 			}
 		case "r":
-			m.checking = true
-			return m, m.runChecks()
+			cmd := m.startWatch()
+			return m, cmd
 		}
 
-	case checkDoneMsg:
-		m.hosts = msg.results
-		m.checking = false
-		if m.once {
-			return m, tea.Quit
-		}
-		return m, tea.Tick(time.Duration(m.config.Interval)*time.Second, func(t time.Time) tea.Msg {
-			return tickMsg(t)
-		})
+	case startMsg:
+		cmd := m.startWatch()
+		return m, cmd
 
-	case tickMsg:
-		m.checking = true
-		return m, m.runChecks()
+	case hostResultMsg:
+		if msg.gen != m.gen {
+			return m, nil // superseded by a manual refresh
+		}
+		for i := range m.hosts {
+			if m.hosts[i].Config.Name == msg.status.Config.Name {
+				m.hosts[i] = msg.status
+				m.tracker.Update(m.hosts[i : i+1])
+			}
+		}
+		m.fresh[msg.status.Config.Name] = true
+		if len(m.fresh) == len(m.hosts) {
+			m.checking = false
+			if m.once {
+				return m, tea.Quit
+			}
+		}
+		return m, waitForResult(m.gen, m.results)
 
 	case spinner.TickMsg:
 		var cmd tea.Cmd
@@ -204,34 +259,59 @@ func (m model) View() string {
 			style = selectedStyle
 		}
 
-		status := offlineStyle.Render("● DOWN")
-		if h.Online {
-			status = onlineStyle.Render("● UP  ")
-		} else if h.LastCheck.IsZero() {
-			status = dimStyle.Render("● ----")
-		}
+		status := healthStyle(h).Render(fmt.Sprintf("● %-4s", statusLabel(h)))
 
 		label := labelStyle.Render(h.Config.Label)
-		host := dimStyle.Render(fmt.Sprintf("(%s@%s)", h.Config.User, h.Config.Host))
+		host := dimStyle.Render(fmt.Sprintf("(%s)", h.Config.Target()))
 
 		line := fmt.Sprintf("%s %s %s", status, label, host)
+		if p := h.Pending; p != nil {
+			line += "  " + flapStyle.Render(fmt.Sprintf("unconfirmed %s %d/%d", p.State, p.Seen, p.Need))
+		}
 
 		if h.Online {
 			details := []string{}
-			if h.CPU != "" {
-				details = append(details, fmt.Sprintf("load:%s", h.CPU))
+			if s := h.Metrics.LoadString(); s != "" {
+				details = append(details, fmt.Sprintf("load:%s", s))
+			}
+			if s := h.Metrics.MemoryString(); s != "" {
+				details = append(details, fmt.Sprintf("mem:%s", s))
+			}
+			if s := h.Metrics.DiskString(); s != "" {
+				details = append(details, fmt.Sprintf("disk:%s", s))
+			}
+			if s := h.Metrics.TempString(); s != "" {
+				details = append(details, fmt.Sprintf("temp:%s", s))
+			}
+			if u := h.Updates; u != nil && u.Pending > 0 {
+				details = append(details, fmt.Sprintf("upd:%d", u.Pending))
 			}
-			if h.Memory != "" {
-				details = append(details, fmt.Sprintf("mem:%s", h.Memory))
+			if u := h.Updates; u != nil && u.RebootRequired {
+				details = append(details, "reboot")
 			}
-			if h.Disk != "" {
-				details = append(details, fmt.Sprintf("disk:%s", h.Disk))
+			if h.Detail != "" {
+				details = append(details, h.Detail)
+			}
+			if h.Latency > 0 && h.Config.Type != "ssh" {
+				details = append(details, "rtt:"+formatLatency(h.Latency))
 			}
 			if len(details) > 0 {
 				line += "  " + dimStyle.Render(strings.Join(details, " | "))
 			}
-		} else if h.Error != "" && i == m.cursor {
-			line += "\n    " + offlineStyle.Render(truncate(h.Error, 60))
+			if len(h.Reasons) > 0 && i == m.cursor {
+				line += "\n    " + healthStyle(h).Render(truncate(strings.Join(h.Reasons, ", "), 60))
+			}
+			if i == m.cursor {
+				for _, d := range hostDetail(h) {
+					line += "\n    " + d
+				}
+			}
+		} else if h.Error != "" && (i == m.cursor || h.HostKeyMismatch) {
+			msg := h.Error
+			if h.Failure != "" {
+				msg = string(h.Failure) + ": " + msg
+			}
+			line += "\n    " + offlineStyle.Render(truncate(msg, 60))
 		}
 
 		if !h.LastCheck.IsZero() {
@@ -247,6 +327,135 @@ func (m model) View() string {
 	return b.String()
 }
 
+// hostDetail returns the extra rows shown under the selected host.
+func hostDetail(h HostStatus) []string {
+	var rows []string
+	if h.Interval > 0 {
+		row := fmt.Sprintf("checked every %.0fs", h.Interval.Seconds())
+		if normal := h.Config.interval(); h.Interval != normal {
+			row += fmt.Sprintf(" while in trouble (normally %.0fs)", normal.Seconds())
+		}
+		rows = append(rows, dimStyle.Render(row))
+	}
+	if h.Metrics != nil {
+		t := h.Config.Thresholds.Disk
+		for _, d := range h.Metrics.Disks {
+			row := fmt.Sprintf("%s %-16s %3.0f%% %s/%s", healthMark(t.level(d.Percent())), d.Mount, d.Percent(), formatBytes(d.Used), formatBytes(d.Total))
+			if d.InodesTotal > 0 {
+				row += fmt.Sprintf("  inodes %.0f%%", d.InodePercent())
+			}
+			if d.FullIn > 0 {
+				row += "  full in " + formatDuration(d.FullIn)
+			}
+			rows = append(rows, row)
+		}
+		for _, ni := range h.Metrics.Net {
+			mark := dimStyle.Render("·")
+			if ni.HasRates && ni.ErrorRate+ni.DropRate > 0 {
+				mark = healthMark(HealthWarning)
+			}
+			rows = append(rows, fmt.Sprintf("%s %-16s %s", mark, ni.Name, ni.describe()))
+		}
+		for _, t := range h.Metrics.Temps {
+			rows = append(rows, fmt.Sprintf("%s %-16s %.0f°C", healthMark(h.Config.Thresholds.Temperature.level(t.Celsius)), t.Name, t.Celsius))
+		}
+		if h.Metrics.Throttled != 0 {
+			mark := dimStyle.Render("·")
+			if h.Metrics.Throttled&0xf != 0 {
+				mark = healthMark(HealthWarning)
+			}
+			rows = append(rows, fmt.Sprintf("%s %-16s %s", mark, "throttle", throttleString(h.Metrics.Throttled)))
+		}
+		if b := h.Metrics.Battery; b != nil {
+			mark := healthMark(HealthOK)
+			if !b.OnAC {
+				mark = healthMark(h.Config.Thresholds.Battery.levelBelow(b.Percent))
+			}
+			rows = append(rows, fmt.Sprintf("%s %-16s %s", mark, "battery", b))
+		}
+	}
+	for _, svc := range h.Services {
+		rows = append(rows, fmt.Sprintf("%s %-16s %s", healthMark(svc.Health), svc.Name, svc.describe()))
+	}
+	for _, c := range h.Containers {
+		mark := dimStyle.Render("·")
+		if c.Pinned || c.Health == "unhealthy" {
+			mark = healthMark(c.health())
+		}
+		rows = append(rows, fmt.Sprintf("%s %-16s %s %s", mark, c.Name, c.describe(), dimStyle.Render(c.Image)))
+	}
+	if h.FailedUnits > 0 {
+		rows = append(rows, fmt.Sprintf("%s %d failed units: %s", healthMark(HealthWarning), h.FailedUnits, strings.Join(h.FailedUnitNames, " ")))
+	}
+	if u := h.Updates; u != nil && u.Manager != "" {
+		mark := healthMark(HealthOK)
+		if u.Security != nil && *u.Security > 0 {
+			mark = healthMark(HealthWarning)
+		} else if u.Pending > 0 {
+			mark = dimStyle.Render("·")
+		}
+		row := fmt.Sprintf("%s %-16s %s", mark, u.Manager, u.pendingString())
+		rows = append(rows, row+dimStyle.Render(" (checked "+formatDuration(time.Since(u.CheckedAt))+" ago)"))
+	}
+	if u := h.Updates; u != nil && u.RebootRequired {
+		rows = append(rows, fmt.Sprintf("%s %-16s %s", healthMark(HealthWarning), "reboot", strings.Join(u.RebootReasons, "; ")))
+	}
+	for _, c := range h.Checks {
+		row := fmt.Sprintf("%s %-16s", healthMark(c.Health), c.Name)
+		switch {
+		case c.Message != "":
+			row += " " + c.Message
+		case c.Value != nil:
+			row += fmt.Sprintf(" %g", *c.Value)
+		}
+		rows = append(rows, row)
+	}
+	if len(h.TopCPU) > 0 {
+		rows = append(rows, dimStyle.Render("top by cpu:"))
+		for _, p := range h.TopCPU {
+			rows = append(rows, "  "+p.String())
+		}
+	}
+	if len(h.TopRSS) > 0 {
+		rows = append(rows, dimStyle.Render("top by memory:"))
+		for _, p := range h.TopRSS {
+			rows = append(rows, "  "+p.String())
+		}
+	}
+	return rows
+}
+
+// healthMark renders a one-character colored health indicator.
+func healthMark(h Health) string {
+	switch h {
+	case HealthOK:
+		return onlineStyle.Render("✓")
+	case HealthWarning:
+		return warnStyle.Render("!")
+	case HealthCritical:
+		return offlineStyle.Render("✗")
+	default:
+		return dimStyle.Render("?")
+	}
+}
+
+// healthStyle picks the status color: green ok, yellow warning, red
+// critical/down, orange while a change is unconfirmed.
+func healthStyle(h HostStatus) lipgloss.Style {
+	switch {
+	case h.LastCheck.IsZero():
+		return dimStyle
+	case h.Pending != nil:
+		return flapStyle
+	case !h.Online || h.Health == HealthCritical:
+		return offlineStyle
+	case h.Health == HealthWarning:
+		return warnStyle
+	default:
+		return onlineStyle
+	}
+}
+
 func truncate(s string, max int) string {
 	if len(s) <= max {
 		return s
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Updates is the package update and reboot state of a host.
+type Updates struct {
+	Manager        string    `json:"manager,omitempty"`  // apt, dnf, pacman or brew; empty if none found
+	Pending        int       `json:"pending"`            // upgradable packages
+	Security       *int      `json:"security,omitempty"` // of which security updates, where the manager knows
+	RebootRequired bool      `json:"reboot_required"`
+	RebootReasons  []string  `json:"reboot_reasons,omitempty"`
+	CheckedAt      time.Time `json:"checked_at"`
+}
+
+// updatesProbe counts pending upgrades with whichever package manager the
+// host has, from its local package lists (nothing is refreshed), and looks
+// for signs a reboot is due: dnf's needs-restarting, and Debian's
+// reboot-required flag or, failing that, a running kernel whose modules are
+// gone or older than the newest installed of the same flavour (-generic,
+// -v8+, .fc39.x86_64...), as several flavours may be installed side by side.
+const updatesProbe = `
+if command -v apt-get >/dev/null; then
+	echo pkg_manager=apt
+	apt-get -s -o Debug::NoLocking=1 dist-upgrade | awk '/^Inst /{n++; if (/-security/) s++} END{printf "pkg_pending=%d\npkg_security=%d\n", n, s}'
+elif command -v dnf >/dev/null; then
+	echo pkg_manager=dnf
+	echo "pkg_pending=$(dnf -q -C check-update | awk 'NF==3 && $1 ~ /\./' | wc -l)"
+	echo "pkg_security=$(dnf -q -C updateinfo list --security | wc -l)"
+elif command -v pacman >/dev/null; then
+	echo pkg_manager=pacman
+	echo "pkg_pending=$(pacman -Qu | wc -l)"
+else
+	for b in brew /opt/homebrew/bin/brew /usr/local/bin/brew; do
+		command -v $b >/dev/null || continue
+		echo pkg_manager=brew
+		echo "pkg_pending=$($b outdated --quiet | wc -l)"
+		break
+	done
+fi
+if command -v needs-restarting >/dev/null; then
+	needs-restarting -r >/dev/null
+	[ $? = 1 ] && echo "reboot=needs-restarting"
+fi
+k=$(uname -r)
+if [ -f /var/run/reboot-required ]; then
+	echo "reboot=reboot-required $(tr '\n' ' ' < /var/run/reboot-required.pkgs)"
+elif [ -n "$(ls /lib/modules)" ] && [ ! -d "/lib/modules/$k" ]; then
+	echo "reboot=kernel $k running, its modules are gone"
+elif [ -d /lib/modules ]; then
+	latest=$(ls /lib/modules | awk -v k="$k" '
+		function flavour(v) { sub(/^[0-9.]+(-[0-9]+)?/, "", v); return v }
+		/^[0-9]/ && flavour($0) == flavour(k)' | sort -V | tail -1)
+	[ -n "$latest" ] && [ "$latest" != "$k" ] && echo "reboot=kernel $k running, $latest installed"
+fi
+echo updates_checked=1
+`
+
+// updates parses the updatesProbe keys, or returns nil if they were not
+// part of this probe.
+func (p probeResult) updates(now time.Time) *Updates {
+	if p.get("updates_checked") == "" {
+		return nil
+	}
+	u := &Updates{Manager: p.get("pkg_manager"), CheckedAt: now}
+	u.Pending, _ = strconv.Atoi(p.get("pkg_pending"))
+	if n, err := strconv.Atoi(p.get("pkg_security")); err == nil {
+		u.Security = &n
+	}
+	for _, r := range p["reboot"] {
+		u.RebootReasons = append(u.RebootReasons, strings.TrimSpace(r))
+	}
+	u.RebootRequired = len(u.RebootReasons) > 0
+	return u
+}
+
+// String summarises the updates, e.g. "12 updates (3 security), reboot
+// required". Empty when there is nothing to report.
+func (u *Updates) String() string {
+	if u == nil {
+		return ""
+	}
+	var parts []string
+	if s := u.pendingString(); s != "" {
+		parts = append(parts, s)
+	}
+	if u.RebootRequired {
+		parts = append(parts, "reboot required")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// pendingString describes the pending updates alone, e.g. "up to date".
+func (u *Updates) pendingString() string {
+	switch {
+	case u.Manager == "":
+		return ""
+	case u.Pending == 0:
+		return "up to date"
+	case u.Security != nil && *u.Security > 0:
+		return fmt.Sprintf("%d updates (%d security)", u.Pending, *u.Security)
+	default:
+		return fmt.Sprintf("%d updates", u.Pending)
+	}
+}
+
+// updateStore remembers each host's last update check. Those checks are
+// slow, so they only join the probe every HostConfig.UpdatesInterval and
+// the last result is reported in between.
+type updateStore struct {
+	mu   sync.Mutex
+	last map[string]*Updates // host name -> last result, never modified
+}
+
+func newUpdateStore() *updateStore {
+	return &updateStore{last: make(map[string]*Updates)}
+}
+
+// due reports whether the next probe of hc should check for updates.
+func (us *updateStore) due(hc HostConfig) bool {
+	if hc.UpdatesInterval < 0 {
+		return false
+	}
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	u, ok := us.last[hc.Name]
+	return !ok || time.Since(u.CheckedAt) >= time.Duration(hc.UpdatesInterval)*time.Second
+}
+
+// result records the updates found by a complete probe and returns the
+// latest known ones for hc.
+func (us *updateStore) result(hc HostConfig, probe probeResult, now time.Time) *Updates {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	if u := probe.updates(now); u != nil && probe.complete() {
+		us.last[hc.Name] = u
+	}
+	return us.last[hc.Name]
+}
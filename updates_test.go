@@ -0,0 +1,107 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestProbeUpdates(t *testing.T) {
+	three := 3
+	zero := 0
+	tests := []struct {
+		name string
+		out  string
+		want *Updates // CheckedAt not compared
+		str  string
+	}{
+		{
+			name: "not checked this time",
+			out:  probeOutput("os=Linux", "probe_end=1"),
+		},
+		{
+			name: "apt with security updates and reboot-required",
+			out: probeOutput("pkg_manager=apt", "pkg_pending=12", "pkg_security=3",
+				"reboot=reboot-required linux-image-6.1.0-20-amd64 libc6 ", "updates_checked=1", "probe_end=1"),
+			want: &Updates{Manager: "apt", Pending: 12, Security: &three, RebootRequired: true,
+				RebootReasons: []string{"reboot-required linux-image-6.1.0-20-amd64 libc6"}},
+			str: "12 updates (3 security), reboot required",
+		},
+		{
+			name: "dnf up to date",
+			out:  probeOutput("pkg_manager=dnf", "pkg_pending=0", "pkg_security=0", "updates_checked=1", "probe_end=1"),
+			want: &Updates{Manager: "dnf", Security: &zero},
+			str:  "up to date",
+		},
+		{
+			name: "pacman has no security count",
+			out:  probeOutput("pkg_manager=pacman", "pkg_pending=7", "reboot=kernel 6.8.2-arch2-1 running, its modules are gone", "updates_checked=1", "probe_end=1"),
+			want: &Updates{Manager: "pacman", Pending: 7, RebootRequired: true,
+				RebootReasons: []string{"kernel 6.8.2-arch2-1 running, its modules are gone"}},
+			str: "7 updates, reboot required",
+		},
+		{
+			name: "no package manager, several reboot reasons",
+			out:  probeOutput("reboot=needs-restarting", "reboot=kernel 6.5.0-27-generic running, 6.5.0-28-generic installed", "updates_checked=1", "probe_end=1"),
+			want: &Updates{RebootRequired: true,
+				RebootReasons: []string{"needs-restarting", "kernel 6.5.0-27-generic running, 6.5.0-28-generic installed"}},
+			str: "reboot required",
+		},
+		{
+			name: "nothing found",
+			out:  probeOutput("updates_checked=1", "probe_end=1"),
+			want: &Updates{},
+		},
+	}
+	now := time.Now()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := parseProbe(tt.out)
+			if err != nil {
+				t.Fatalf("parseProbe: %v", err)
+			}
+			got := p.updates(now)
+			if tt.want == nil {
+				if got != nil {
+					t.Fatalf("updates = %+v, want nil", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatalf("updates = nil, want %+v", tt.want)
+			}
+			if got.Manager != tt.want.Manager || got.Pending != tt.want.Pending ||
+				got.RebootRequired != tt.want.RebootRequired || !slices.Equal(got.RebootReasons, tt.want.RebootReasons) ||
+				(got.Security == nil) != (tt.want.Security == nil) ||
+				(got.Security != nil && *got.Security != *tt.want.Security) || !got.CheckedAt.Equal(now) {
+				t.Errorf("updates = %+v, want %+v", got, tt.want)
+			}
+			if s := got.String(); s != tt.str {
+				t.Errorf("String() = %q, want %q", s, tt.str)
+			}
+		})
+	}
+}
+
+func TestUpdateStoreKeepsCompleteResults(t *testing.T) {
+	us := &updateStore{last: make(map[string]*Updates)}
+	hc := HostConfig{Name: "u", UpdatesInterval: 3600}
+	if !us.due(hc) {
+		t.Fatal("first probe should check for updates")
+	}
+	cut, _ := parseProbe(probeOutput("pkg_manager=apt", "pkg_pending=2", "updates_checked=1"))
+	if u := us.result(hc, cut, time.Now()); u != nil || !us.due(hc) {
+		t.Errorf("incomplete probe stored %+v", u)
+	}
+	full, _ := parseProbe(probeOutput("pkg_manager=apt", "pkg_pending=2", "updates_checked=1", "probe_end=1"))
+	if u := us.result(hc, full, time.Now()); u == nil || u.Pending != 2 || us.due(hc) {
+		t.Errorf("complete probe: got %+v, due %v", u, us.due(hc))
+	}
+	skipped, _ := parseProbe(probeOutput("probe_end=1"))
+	if u := us.result(hc, skipped, time.Now()); u == nil || u.Pending != 2 {
+		t.Errorf("probe without updates lost the last result: %+v", u)
+	}
+	if hc.UpdatesInterval = -1; us.due(hc) {
+		t.Error("updates_interval -1 should never be due")
+	}
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"sync"
 	"time"
@@ -10,7 +13,7 @@ import (
 
 // HostHistory tracks recent check results for sparkline display.
 type HostHistory struct {
-	Checks []bool    // true=up, false=down (newest last)
+	Checks []bool // true=up, false=down (newest last)
 	Times  []time.Time
 	Max    int
 }
@@ -58,6 +61,7 @@ func (h *HostHistory) Sparkline() string {
 // WebServer serves a simple dashboard for Pulse host monitoring.
 type WebServer struct {
 	cfg     *Config
+	mon     *monitor
 	tracker *StateTracker
 	mu      sync.RWMutex
 	latest  []HostStatus
@@ -65,76 +69,143 @@ type WebServer struct {
 	port    int
 }
 
-func NewWebServer(cfg *Config, port int) *WebServer {
+func NewWebServer(cfg *Config, port int, mon *monitor) *WebServer {
 	history := make(map[string]*HostHistory)
 	for _, h := range cfg.Hosts {
 		history[h.Name] = NewHostHistory(60)
 	}
 	return &WebServer{
 		cfg:     cfg,
+		mon:     mon,
 		tracker: NewStateTracker(cfg.Notify),
 		history: history,
 		port:    port,
 	}
 }
 
-func (ws *WebServer) Run() error {
+// Run serves the dashboard until ctx is cancelled.
+func (ws *WebServer) Run(ctx context.Context) error {
 	// Start background checker
-	go ws.pollLoop()
+	go ws.pollLoop(ctx)
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", ws.handleDashboard)
 	mux.HandleFunc("/api/status", ws.handleAPI)
+	mux.HandleFunc("GET /host/{name}", ws.handleHostPage)
+	mux.HandleFunc("GET /api/host/{name}", ws.handleHostAPI)
+	mux.HandleFunc("POST /api/heartbeat/{name}", ws.handleHeartbeat)
 
 	addr := fmt.Sprintf(":%d", ws.port)
+	srv := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+		ws.mon.close()
+	}()
 	fmt.Printf("Pulse web dashboard: http://localhost%s\n", addr)
-	return http.ListenAndServe(addr, mux)
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
 }
 
-func (ws *WebServer) pollLoop() {
+// pollLoop records each host's result as it arrives.
+func (ws *WebServer) pollLoop(ctx context.Context) {
+	index := make(map[string]int)
+	ws.mu.Lock()
+	ws.latest = make([]HostStatus, len(ws.cfg.Hosts))
+	for i, h := range ws.cfg.Hosts {
+		ws.latest[i] = HostStatus{Config: h}
+		index[h.Name] = i
+	}
+	ws.mu.Unlock()
+
+	results := make(chan HostStatus, len(ws.cfg.Hosts))
+	go ws.mon.watchHosts(ctx, ws.cfg, results)
 	for {
-		results := checkAllHosts(ws.cfg)
-		ws.mu.Lock()
-		ws.latest = results
-		ws.tracker.Update(results)
-		for _, r := range results {
+		select {
+		case r := <-results:
+			i := index[r.Config.Name]
+			ws.mu.Lock()
+			ws.latest[i] = r
+			ws.tracker.Update(ws.latest[i : i+1])
 			if h, ok := ws.history[r.Config.Name]; ok {
 				h.Add(r.Online)
 			}
+			ws.mu.Unlock()
+		case <-ctx.Done():
+			return
 		}
-		ws.mu.Unlock()
-		time.Sleep(time.Duration(ws.cfg.Interval) * time.Second)
 	}
 }
 
 func (ws *WebServer) handleAPI(w http.ResponseWriter, r *http.Request) {
 	ws.mu.RLock()
-	results := ws.latest
+	out := make([]jsonResult, len(ws.latest))
+	for i, r := range ws.latest {
+		out[i] = ws.result(r)
+	}
 	ws.mu.RUnlock()
 
-	out := make([]jsonResult, len(results))
-	for i, r := range results {
-		jr := jsonResult{
-			Name:    r.Config.Label,
-			Host:    r.Config.Host,
-			Online:  r.Online,
-			CPU:     r.CPU,
-			Memory:  r.Memory,
-			Disk:    r.Disk,
-			Uptime:  r.Uptime,
-			Error:   r.Error,
-			CheckAt: r.LastCheck.Format(time.RFC3339),
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+// handleHostAPI returns the latest result for one host by config name.
+func (ws *WebServer) handleHostAPI(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+	for _, res := range ws.latest {
+		if res.Config.Name == name {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(ws.result(res))
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+// handleHeartbeat records a push from a heartbeat host. The body may be
+// empty or an agent payload, e.g. {"metrics": {...}}.
+func (ws *WebServer) handleHeartbeat(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	for _, hc := range ws.cfg.Hosts {
+		if hc.Name != name || hc.Type != "heartbeat" {
+			continue
+		}
+		if !validToken(r, hc.Token) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="pulse"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, 4<<20))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
-		if h, ok := ws.history[r.Config.Name]; ok && len(h.Checks) > 0 {
-			jr.Sparkline = h.Sparkline()
-			jr.UptimePercent = h.UptimePercent()
-			jr.CheckCount = len(h.Checks)
+		body = bytes.TrimSpace(body)
+		var p agentPayload
+		if len(body) > 0 && json.Unmarshal(body, &p) != nil {
+			http.Error(w, "body must be empty or a JSON status", http.StatusBadRequest)
+			return
 		}
-		out[i] = jr
+		ws.mon.heartbeats.record(name, body)
+		w.WriteHeader(http.StatusNoContent)
+		return
 	}
+	http.NotFound(w, r)
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(out)
+// result converts a status to its API form, adding uptime history.
+func (ws *WebServer) result(r HostStatus) jsonResult {
+	jr := newJSONResult(r)
+	if h, ok := ws.history[r.Config.Name]; ok && len(h.Checks) > 0 {
+		jr.Sparkline = h.Sparkline()
+		jr.UptimePercent = h.UptimePercent()
+		jr.CheckCount = len(h.Checks)
+	}
+	return jr
 }
 
 func (ws *WebServer) handleDashboard(w http.ResponseWriter, r *http.Request) {
@@ -142,26 +213,37 @@ func (ws *WebServer) handleDashboard(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, dashboardHTML)
 }
 
-const dashboardHTML = `<!DOCTYPE html>
-<html lang="en">
-<head>
-<meta charset="utf-8">
-<meta name="viewport" content="width=device-width, initial-scale=1">
-<title>Pulse</title>
-<style>
-  * { margin: 0; padding: 0; box-sizing: border-box; }
+func (ws *WebServer) handleHostPage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, hostHTML)
+}
+
+// pageStyle is shared by the dashboard and the host pages.
+const pageStyle = `  * { margin: 0; padding: 0; box-sizing: border-box; }
   body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", system-ui, sans-serif; background: #0f1117; color: #e0e0e0; padding: 2rem; }
   h1 { font-size: 1.5rem; margin-bottom: 1.5rem; color: #fff; }
   h1 span { color: #6366f1; }
   .grid { display: grid; grid-template-columns: repeat(auto-fill, minmax(320px, 1fr)); gap: 1rem; }
   .card { background: #1a1d27; border-radius: 12px; padding: 1.25rem; border: 1px solid #2a2d3a; transition: border-color 0.2s; }
-  .card.up { border-left: 4px solid #22c55e; }
-  .card.down { border-left: 4px solid #ef4444; }
+  .card.ok { border-left: 4px solid #22c55e; }
+  .card.warning { border-left: 4px solid #eab308; }
+  .card.critical, .card.down { border-left: 4px solid #ef4444; }
+  .card.unknown { border-left: 4px solid #555; }
+  .card.flapping { border-left: 4px solid #f97316; }
   .card-header { display: flex; justify-content: space-between; align-items: center; margin-bottom: 0.75rem; }
   .host-name { font-weight: 600; font-size: 1.1rem; }
   .badge { padding: 0.2rem 0.6rem; border-radius: 9999px; font-size: 0.75rem; font-weight: 600; text-transform: uppercase; }
-  .badge.up { background: #22c55e20; color: #22c55e; }
-  .badge.down { background: #ef444420; color: #ef4444; }
+  .badge.ok { background: #22c55e20; color: #22c55e; }
+  .badge.warning { background: #eab30820; color: #eab308; }
+  .badge.critical, .badge.down { background: #ef444420; color: #ef4444; }
+  .badge.unknown { background: #55555520; color: #888; }
+  .badge.flapping { background: #f9731620; color: #f97316; }
+  .card.keyfail { border: 2px solid #ef4444; background: #2a1215; }
+  .badge.keyfail { background: #ef4444; color: #fff; }
+  .rows { margin-top: 0.5rem; font-size: 0.8rem; }
+  .row { display: flex; justify-content: space-between; padding: 0.15rem 0; border-bottom: 1px solid #22252f; }
+  .row .ok { color: #22c55e; } .row .warning { color: #eab308; } .row .critical { color: #ef4444; } .row .unknown { color: #888; }
+  .reasons { color: #eab308; font-size: 0.8rem; margin-top: 0.5rem; }
   .host-addr { color: #888; font-size: 0.85rem; margin-bottom: 0.75rem; }
   .metrics { display: grid; grid-template-columns: 1fr 1fr; gap: 0.5rem; }
   .metric { background: #12141c; border-radius: 8px; padding: 0.5rem 0.75rem; }
@@ -173,14 +255,46 @@ const dashboardHTML = `<!DOCTYPE html>
   .uptime-pct { font-size: 0.75rem; color: #888; margin-top: 0.25rem; }
   .footer { margin-top: 2rem; color: #555; font-size: 0.8rem; text-align: center; }
   .loading { text-align: center; padding: 3rem; color: #666; }
-</style>
+  .back { color: #6366f1; text-decoration: none; font-size: 0.85rem; }
+  a.host-name { color: inherit; text-decoration: none; }
+  a.host-name:hover { text-decoration: underline; }
+  h2 { font-size: 0.8rem; color: #666; text-transform: uppercase; letter-spacing: 0.05em; margin: 1.25rem 0 0.25rem; }
+  table { width: 100%; border-collapse: collapse; font-size: 0.85rem; }
+  th { text-align: left; color: #666; font-weight: 500; padding: 0.25rem 0.5rem 0.25rem 0; }
+  td { padding: 0.2rem 0.5rem 0.2rem 0; border-bottom: 1px solid #22252f; font-family: monospace; }
+  td.num, th.num { text-align: right; }
+  td .ok { color: #22c55e; } td .warning { color: #eab308; } td .critical { color: #ef4444; } td .unknown { color: #888; }
+`
+
+// pageScript holds helpers shared by the dashboard and the host pages.
+// Everything a host reports (names, command lines, check output, pushed
+// payloads) goes through esc before it reaches innerHTML.
+const pageScript = `function esc(s) {
+  return String(s ?? '').replace(/[&<>"']/g, c => ({'&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;', "'": '&#39;'})[c]);
+}
+function fmtBytes(b) {
+  const units = ['B', 'Ki', 'Mi', 'Gi', 'Ti'];
+  let i = 0;
+  while (b >= 1024 && i < units.length - 1) { b /= 1024; i++; }
+  return (i ? b.toFixed(1) : b.toFixed(0)) + units[i];
+}
+`
+
+const dashboardHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Pulse</title>
+<style>
+` + pageStyle + `</style>
 </head>
 <body>
 <h1><span>&#9679;</span> Pulse</h1>
 <div id="grid" class="grid"><div class="loading">Loading...</div></div>
-<div class="footer">Auto-refreshes every <span id="interval">30</span>s</div>
+<div class="footer">Hosts update as their checks finish; this page refreshes every 5s</div>
 <script>
-async function refresh() {
+` + pageScript + `async function refresh() {
   try {
     const res = await fetch('/api/status');
     const hosts = await res.json();
@@ -190,22 +304,35 @@ async function refresh() {
       return;
     }
     grid.innerHTML = hosts.map(h => {
-      const cls = h.online ? 'up' : 'down';
+      const cls = h.host_key_mismatch ? 'keyfail' : h.pending ? 'flapping' : h.online ? h.health : (h.health === 'unknown' ? 'unknown' : 'down');
+      const badge = h.host_key_mismatch ? 'host key mismatch' : h.pending ? ` + "`" + `${h.pending.state}? ${h.pending.seen}/${h.pending.need}` + "`" + ` : cls;
       const metrics = h.online ? ` + "`" + `
         <div class="metrics">
-          ${h.cpu ? ` + "`" + `<div class="metric"><div class="metric-label">Load</div><div class="metric-value">${h.cpu}</div></div>` + "`" + ` : ''}
-          ${h.memory ? ` + "`" + `<div class="metric"><div class="metric-label">Memory</div><div class="metric-value">${h.memory}</div></div>` + "`" + ` : ''}
-          ${h.disk ? ` + "`" + `<div class="metric"><div class="metric-label">Disk</div><div class="metric-value">${h.disk}</div></div>` + "`" + ` : ''}
-          ${h.uptime ? ` + "`" + `<div class="metric"><div class="metric-label">Uptime</div><div class="metric-value">${h.uptime}</div></div>` + "`" + ` : ''}
-        </div>` + "`" + ` : ` + "`" + `<div class="error-msg">${h.error || 'Unreachable'}</div>` + "`" + `;
-      const sparkline = h.sparkline ? ` + "`" + `<div class="sparkline">${h.sparkline.split('').map(c => c === '█' ? c : ` + "`" + `<span class="down-char">${c}</span>` + "`" + `).join('')}</div><div class="uptime-pct">${h.uptime_percent.toFixed(1)}% uptime (${h.check_count} checks)</div>` + "`" + ` : '';
-      return ` + "`" + `<div class="card ${cls}">
+          ${h.cpu ? ` + "`" + `<div class="metric"><div class="metric-label">Load</div><div class="metric-value">${esc(h.cpu)}</div></div>` + "`" + ` : ''}
+          ${h.memory ? ` + "`" + `<div class="metric"><div class="metric-label">Memory</div><div class="metric-value">${esc(h.memory)}</div></div>` + "`" + ` : ''}
+          ${h.disk ? ` + "`" + `<div class="metric"><div class="metric-label">Disk</div><div class="metric-value">${esc(h.disk)}</div></div>` + "`" + ` : ''}
+          ${h.metrics && h.metrics.temperatures ? ` + "`" + `<div class="metric"><div class="metric-label">Temp</div><div class="metric-value">${Math.max(...h.metrics.temperatures.map(t => t.celsius)).toFixed(0)}&deg;C</div></div>` + "`" + ` : ''}
+          ${h.metrics && h.metrics.battery ? ` + "`" + `<div class="metric"><div class="metric-label">Battery</div><div class="metric-value">${h.metrics.battery.percent.toFixed(0)}%${h.metrics.battery.on_ac ? ' &#9889;' : ''}</div></div>` + "`" + ` : ''}
+          ${h.uptime ? ` + "`" + `<div class="metric"><div class="metric-label">Uptime</div><div class="metric-value">${esc(h.uptime)}</div></div>` + "`" + ` : ''}
+          ${h.detail ? ` + "`" + `<div class="metric"><div class="metric-label">${esc(h.type)}</div><div class="metric-value">${esc(h.detail)}</div></div>` + "`" + ` : ''}
+          ${h.latency_ms ? ` + "`" + `<div class="metric"><div class="metric-label">Latency</div><div class="metric-value">${h.latency_ms.toFixed(0)}ms</div></div>` + "`" + ` : ''}
+        </div>` + "`" + ` : ` + "`" + `<div class="error-msg">${h.failure ? '<b>' + esc(h.failure) + '</b> ' : ''}${esc(h.error || 'Unreachable')}</div>` + "`" + `;
+      const sparkline = h.sparkline ? ` + "`" + `<div class="sparkline">${h.sparkline.split('').map(c => c === '█' ? c : ` + "`" + `<span class="down-char">${esc(c)}</span>` + "`" + `).join('')}</div><div class="uptime-pct">${h.uptime_percent.toFixed(1)}% uptime (${h.check_count} checks)</div>` + "`" + ` : '';
+      return ` + "`" + `<div class="card ${esc(cls)}">
         <div class="card-header">
-          <span class="host-name">${h.name}</span>
-          <span class="badge ${cls}">${cls}</span>
+          <a class="host-name" href="/host/${encodeURIComponent(h.id)}">${esc(h.name)}</a>
+          <span class="badge ${esc(cls)}">${esc(badge)}</span>
         </div>
-        <div class="host-addr">${h.host}</div>
+        <div class="host-addr">${esc(h.host)}</div>
         ${metrics}
+        ${h.metrics && h.metrics.disks && h.metrics.disks.length > 1 ? ` + "`" + `<div class="rows">${h.metrics.disks.map(d => ` + "`" + `<div class="row"><span>${esc(d.mount)}</span><span>${(d.used_bytes / (d.used_bytes + d.free_bytes) * 100).toFixed(0)}%${d.inodes_total ? ' · inodes ' + (d.inodes_used / d.inodes_total * 100).toFixed(0) + '%' : ''}${d.full_in_seconds ? ' · full in ' + (d.full_in_seconds / 3600).toFixed(0) + 'h' : ''}</span></div>` + "`" + `).join('')}</div>` + "`" + ` : ''}
+        ${h.metrics && h.metrics.interfaces ? ` + "`" + `<div class="rows">${h.metrics.interfaces.map(n => ` + "`" + `<div class="row"><span>${esc(n.name)}</span><span class="${n.errors_per_sec + n.drops_per_sec > 0 ? 'warning' : ''}">&darr;${fmtBytes(n.rx_bytes_per_sec)}/s &uarr;${fmtBytes(n.tx_bytes_per_sec)}/s${n.errors_per_sec + n.drops_per_sec > 0 ? ' · ' + (n.errors_per_sec + n.drops_per_sec).toFixed(1) + ' err/s' : ''}</span></div>` + "`" + `).join('')}</div>` + "`" + ` : ''}
+        ${h.services ? ` + "`" + `<div class="rows">${h.services.map(s => ` + "`" + `<div class="row"><span>${esc(s.name)}</span><span class="${esc(s.health)}">${esc(s.active_state)}${s.sub_state && s.sub_state !== s.active_state ? ' (' + esc(s.sub_state) + ')' : ''}</span></div>` + "`" + `).join('')}</div>` + "`" + ` : ''}
+        ${h.containers ? ` + "`" + `<div class="rows">${h.containers.map(c => ` + "`" + `<div class="row"><span>${c.pinned ? '&#128204; ' : ''}${esc(c.name)}</span><span class="${c.state === 'running' && c.health !== 'unhealthy' ? 'ok' : (c.pinned ? 'critical' : 'warning')}">${esc(c.state)}${c.health ? ' (' + esc(c.health) + ')' : ''}${c.restarts ? ', ' + c.restarts + ' restarts' : ''}</span></div>` + "`" + `).join('')}</div>` + "`" + ` : ''}
+        ${h.failed_units ? ` + "`" + `<div class="reasons">failed: ${h.failed_units.map(esc).join(', ')}</div>` + "`" + ` : ''}
+        ${h.updates && (h.updates.manager || h.updates.reboot_required) ? ` + "`" + `<div class="rows"><div class="row"><span>${esc(h.updates.manager || 'updates')}</span><span class="${h.updates.security ? 'warning' : ''}">${h.updates.manager ? (h.updates.pending ? h.updates.pending + ' updates' + (h.updates.security ? ' (' + h.updates.security + ' security)' : '') : 'up to date') : ''}</span></div>${h.updates.reboot_required ? '<div class="row"><span>reboot</span><span class="warning">required</span></div>' : ''}</div>` + "`" + ` : ''}
+        ${h.checks ? ` + "`" + `<div class="rows">${h.checks.map(c => ` + "`" + `<div class="row"><span>${esc(c.name)}</span><span class="${esc(c.health)}">${esc(c.message || (c.value !== undefined ? c.value : c.health))}</span></div>` + "`" + `).join('')}</div>` + "`" + ` : ''}
+        ${h.online && h.reasons ? ` + "`" + `<div class="reasons">${h.reasons.map(esc).join(', ')}</div>` + "`" + ` : ''}
         ${sparkline}
       </div>` + "`" + `;
     }).join('');
@@ -214,7 +341,80 @@ async function refresh() {
   }
 }
 refresh();
-setInterval(refresh, 30000);
+setInterval(refresh, 5000);
+</script>
+</body>
+</html>`
+
+// hostHTML shows everything known about one host, including the top
+// processes snapshot.
+const hostHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Pulse</title>
+<style>
+` + pageStyle + `</style>
+</head>
+<body>
+<a class="back" href="/">&larr; all hosts</a>
+<h1 id="title"><span>&#9889;</span> Pulse</h1>
+<div id="host" class="card"><div class="loading">Loading...</div></div>
+<script>
+` + pageScript + `function table(head, rows) {
+  if (!rows || !rows.length) return '';
+  return ` + "`" + `<table><tr>${head.map(h => ` + "`" + `<th class="${h.num ? 'num' : ''}">${h.label}</th>` + "`" + `).join('')}</tr>${rows.map(r => ` + "`" + `<tr>${r.map((c, i) => ` + "`" + `<td class="${head[i].num ? 'num' : ''}">${c}</td>` + "`" + `).join('')}</tr>` + "`" + `).join('')}</table>` + "`" + `;
+}
+function procs(title, list) {
+  if (!list) return '';
+  return ` + "`" + `<h2>${title}</h2>` + "`" + ` + table(
+    [{label: 'PID', num: true}, {label: 'Command'}, {label: 'CPU', num: true}, {label: 'RSS', num: true}],
+    list.map(p => [p.pid, esc(p.command), p.cpu_percent.toFixed(1) + '%', fmtBytes(p.rss_bytes)]));
+}
+async function refresh() {
+  const name = decodeURIComponent(location.pathname.split('/').pop());
+  const res = await fetch('/api/host/' + encodeURIComponent(name));
+  const el = document.getElementById('host');
+  if (!res.ok) {
+    el.innerHTML = '<div class="loading">No such host</div>';
+    return;
+  }
+  const h = await res.json();
+  const m = h.metrics || {};
+  const cls = h.host_key_mismatch ? 'keyfail' : h.pending ? 'flapping' : h.online ? h.health : (h.health === 'unknown' ? 'unknown' : 'down');
+  document.title = h.name + ' - Pulse';
+  el.className = 'card ' + cls;
+  el.innerHTML = ` + "`" + `
+    <div class="card-header">
+      <span class="host-name">${esc(h.name)}</span>
+      <span class="badge ${esc(cls)}">${h.host_key_mismatch ? 'host key mismatch' : cls}</span>
+    </div>
+    <div class="host-addr">${esc(h.host)} &middot; checked ${new Date(h.checked_at).toLocaleTimeString()}${h.interval_seconds ? ' &middot; every ' + h.interval_seconds.toFixed(0) + 's' : ''}</div>
+    ${h.error ? ` + "`" + `<div class="error-msg">${h.failure ? '<b>' + esc(h.failure) + '</b> ' : ''}${esc(h.error)}</div>` + "`" + ` : ''}
+    ${h.reasons ? ` + "`" + `<div class="reasons">${h.reasons.map(esc).join(', ')}</div>` + "`" + ` : ''}
+    <div class="metrics" style="margin-top: 0.75rem">
+      ${h.cpu ? ` + "`" + `<div class="metric"><div class="metric-label">Load</div><div class="metric-value">${esc(h.cpu)}</div></div>` + "`" + ` : ''}
+      ${h.memory ? ` + "`" + `<div class="metric"><div class="metric-label">Memory</div><div class="metric-value">${esc(h.memory)}</div></div>` + "`" + ` : ''}
+      ${h.uptime ? ` + "`" + `<div class="metric"><div class="metric-label">Uptime</div><div class="metric-value">${esc(h.uptime)}</div></div>` + "`" + ` : ''}
+      ${h.detail ? ` + "`" + `<div class="metric"><div class="metric-label">${esc(h.type)}</div><div class="metric-value">${esc(h.detail)}</div></div>` + "`" + ` : ''}
+    </div>
+    ${procs('Top processes by CPU', h.top_cpu)}
+    ${procs('Top processes by memory', h.top_rss)}
+    ${m.disks ? '<h2>Disks</h2>' + table([{label: 'Mount'}, {label: 'Used', num: true}, {label: 'Size', num: true}, {label: 'Inodes', num: true}],
+      m.disks.map(d => [esc(d.mount), (d.used_bytes / (d.used_bytes + d.free_bytes) * 100).toFixed(0) + '%', fmtBytes(d.total_bytes), d.inodes_total ? (d.inodes_used / d.inodes_total * 100).toFixed(0) + '%' : ''])) : ''}
+    ${m.interfaces ? '<h2>Network</h2>' + table([{label: 'Interface'}, {label: 'In', num: true}, {label: 'Out', num: true}, {label: 'Errors+drops', num: true}],
+      m.interfaces.map(n => [esc(n.name), fmtBytes(n.rx_bytes_per_sec) + '/s', fmtBytes(n.tx_bytes_per_sec) + '/s', (n.errors_per_sec + n.drops_per_sec).toFixed(1) + '/s'])) : ''}
+    ${m.temperatures ? '<h2>Sensors</h2>' + table([{label: 'Sensor'}, {label: 'Temp', num: true}], m.temperatures.map(t => [esc(t.name), t.celsius.toFixed(0) + '&deg;C'])) : ''}
+    ${h.services ? '<h2>Services</h2>' + table([{label: 'Unit'}, {label: 'State'}, {label: 'Restarts', num: true}], h.services.map(s => [esc(s.name), ` + "`" + `<span class="${esc(s.health)}">${esc(s.active_state)} (${esc(s.sub_state)})</span>` + "`" + `, s.restarts])) : ''}
+    ${h.containers ? '<h2>Containers</h2>' + table([{label: 'Name'}, {label: 'Image'}, {label: 'State'}, {label: 'Restarts', num: true}], h.containers.map(c => [esc(c.name), esc(c.image), esc(c.state + (c.health ? ' (' + c.health + ')' : '')), c.restarts])) : ''}
+    ${h.updates && (h.updates.manager || h.updates.reboot_required) ? '<h2>Updates</h2>' + table([{label: 'Manager'}, {label: 'Pending', num: true}, {label: 'Security', num: true}, {label: 'Reboot'}],
+      [[esc(h.updates.manager || '-'), h.updates.pending, h.updates.security !== undefined ? h.updates.security : '-', h.updates.reboot_required ? ` + "`" + `<span class="warning">${esc(h.updates.reboot_reasons.join('; '))}</span>` + "`" + ` : 'no']]) : ''}
+    ${h.checks ? '<h2>Checks</h2>' + table([{label: 'Check'}, {label: 'Result'}], h.checks.map(c => [esc(c.name), ` + "`" + `<span class="${esc(c.health)}">${esc(c.message || (c.value !== undefined ? c.value : c.health))}</span>` + "`" + `])) : ''}
+  ` + "`" + `;
+}
+refresh();
+setInterval(refresh, 5000);
 </script>
 </body>
 </html>`